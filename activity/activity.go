@@ -0,0 +1,145 @@
+// Package activity tracks how active each member is, for leaderboards and personal stats.
+// Members can opt out, or in if activity.opt_in is set, and tracking can be turned off entirely with activity.track_users
+package activity
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Strum355/log"
+	"github.com/UCCNetsoc/discord-bot/database"
+	"github.com/spf13/viper"
+)
+
+type activityKey struct {
+	server  string
+	channel string
+	userID  string
+}
+
+var (
+	// Choices members have made about being tracked
+	preferences     = make(map[string]bool)
+	preferencesLock sync.RWMutex
+
+	// Activity which hasn't been saved yet
+	unsaved     = make(map[activityKey]*database.UserActivity)
+	unsavedLock sync.Mutex
+
+	// Held while saving activity or changing whether a member is tracked,
+	// so activity taken to be saved can't be written after the member's has been deleted
+	saveLock sync.Mutex
+)
+
+// Load the choices members have made about being tracked and periodically save their activity
+func Load() {
+	loaded, err := database.ActivityPreferences()
+	if err != nil {
+		log.WithError(err).Error("Failed to load activity preferences")
+	} else {
+		preferencesLock.Lock()
+		preferences = loaded
+		preferencesLock.Unlock()
+	}
+	go func() {
+		for {
+			<-time.After(viper.GetDuration("activity.save_interval"))
+			Flush()
+		}
+	}()
+}
+
+// Enabled reports whether per user tracking is turned on
+func Enabled() bool {
+	return viper.GetBool("activity.track_users")
+}
+
+// Tracked reports whether a member's activity is tracked
+func Tracked(userID string) bool {
+	if !Enabled() {
+		return false
+	}
+	preferencesLock.RLock()
+	tracked, chosen := preferences[userID]
+	preferencesLock.RUnlock()
+	if !chosen {
+		return !viper.GetBool("activity.opt_in")
+	}
+	return tracked
+}
+
+// SetTracked stores whether a member wants to be tracked, deleting their activity if they don't
+func SetTracked(userID string, tracked bool) error {
+	saveLock.Lock()
+	defer saveLock.Unlock()
+	if err := database.SetActivityPreference(userID, tracked); err != nil {
+		return err
+	}
+	preferencesLock.Lock()
+	preferences[userID] = tracked
+	preferencesLock.Unlock()
+	if tracked {
+		return nil
+	}
+	unsavedLock.Lock()
+	for key := range unsaved {
+		if key.userID == userID {
+			delete(unsaved, key)
+		}
+	}
+	unsavedLock.Unlock()
+	return database.DeleteUserActivity(userID)
+}
+
+// Message counts a message sent by a member, if they're tracked
+func Message(server, channel, userID string) {
+	add(server, channel, userID, 1, 0)
+}
+
+// Voice counts time a member spent in a voice channel, if they're tracked
+func Voice(server, channel, userID string, duration time.Duration) {
+	add(server, channel, userID, 0, int(duration.Seconds()))
+}
+
+func add(server, channel, userID string, messages, voiceSeconds int) {
+	if !Tracked(userID) {
+		return
+	}
+	unsavedLock.Lock()
+	defer unsavedLock.Unlock()
+	key := activityKey{server, channel, userID}
+	a, ok := unsaved[key]
+	if !ok {
+		a = &database.UserActivity{Server: server, Channel: channel, UserID: userID}
+		unsaved[key] = a
+	}
+	a.Messages += messages
+	a.VoiceSeconds += voiceSeconds
+}
+
+// Flush saves any activity which hasn't been saved yet, counting it towards today. It should be called before exiting
+func Flush() {
+	saveLock.Lock()
+	defer saveLock.Unlock()
+	unsavedLock.Lock()
+	batch := unsaved
+	unsaved = make(map[activityKey]*database.UserActivity)
+	unsavedLock.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	now := time.Now()
+	activities := make([]*database.UserActivity, 0, len(batch))
+	for _, a := range batch {
+		a.Day = now
+		activities = append(activities, a)
+	}
+	if err := database.AddUserActivity(activities); err != nil {
+		log.WithError(err).WithFields(log.Fields{"activities": len(activities)}).Error("Failed to save user activity")
+		// Keep the activity to retry with the next batch
+		for _, a := range activities {
+			add(a.Server, a.Channel, a.UserID, a.Messages, a.VoiceSeconds)
+		}
+	}
+}
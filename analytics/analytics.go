@@ -0,0 +1,160 @@
+// Package analytics summarises how the public server has grown and how active it's been
+package analytics
+
+import (
+	"time"
+
+	"github.com/UCCNetsoc/discord-bot/config"
+	"github.com/UCCNetsoc/discord-bot/database"
+	"github.com/spf13/viper"
+)
+
+// Count is a number of members or events on a day, or in the week starting on it
+type Count struct {
+	Date  time.Time
+	Count int
+}
+
+// Totals are the number of each member event in a period
+type Totals struct {
+	Joins               int
+	Registrations       int
+	Leaves              int
+	FailedRegistrations int
+}
+
+// Report summarises a period, with the totals of the period before it to show trends
+type Report struct {
+	From         time.Time
+	To           time.Time
+	StartMembers int // Members at the start of the period
+	Members      []Count
+	Totals   Totals
+	Previous Totals
+	Weekly   []Count // Registrations in each week
+	Channels []*database.ChannelActivity
+}
+
+// Build a report of the period ending now, with current being how many members the server has now
+func Build(current int, period time.Duration, channels int) (*Report, error) {
+	to := time.Now()
+	from := to.Add(-period)
+	// The previous period is needed for trends, and the start of the first week for the weekly counts
+	earliest := from.Add(-period)
+	if week := startOfWeek(from); week.Before(earliest) {
+		earliest = week
+	}
+	events, err := database.MemberEvents(earliest, to)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{
+		From:         from,
+		To:           to,
+		StartMembers: membersAt(events, current, from),
+		Members:      membersOverTime(events, current, from, to),
+		Totals:       totals(events, from, to),
+		Previous:     totals(events, from.Add(-period), from),
+		Weekly:       weekly(events, database.MemberRegistered, from, to),
+	}
+	servers := viper.Get("discord.servers").(*config.Servers)
+	report.Channels, err = database.ChannelActivities(servers.PublicServer, from, to, channels)
+	return report, err
+}
+
+// MembersOverTime returns how many members the server had at the end of each day from the start of from's day until to
+func MembersOverTime(current int, from, to time.Time) ([]Count, error) {
+	events, err := database.MemberEvents(startOfDay(from), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return membersOverTime(events, current, from, to), nil
+}
+
+// RegistrationsPerWeek returns how many members registered in each week from the start of from's week until to
+func RegistrationsPerWeek(from, to time.Time) ([]Count, error) {
+	events, err := database.MemberEvents(startOfWeek(from), to)
+	if err != nil {
+		return nil, err
+	}
+	return weekly(events, database.MemberRegistered, from, to), nil
+}
+
+// membersOverTime returns how many members there were at the end of each day.
+// events must cover from the start of from's day until now
+func membersOverTime(events []*database.MemberEvent, current int, from, to time.Time) []Count {
+	counts := []Count{}
+	for day := startOfDay(from); day.Before(to); day = day.AddDate(0, 0, 1) {
+		counts = append(counts, Count{Date: day, Count: membersAt(events, current, day.AddDate(0, 0, 1))})
+	}
+	return counts
+}
+
+// membersAt works backwards from the current number of members, undoing the joins and leaves since t.
+// events must cover from t until now
+func membersAt(events []*database.MemberEvent, current int, t time.Time) int {
+	members := current
+	for _, event := range events {
+		if event.Created.Before(t) {
+			continue
+		}
+		switch event.Kind {
+		case database.MemberJoined:
+			members--
+		case database.MemberLeft:
+			members++
+		}
+	}
+	return members
+}
+
+func totals(events []*database.MemberEvent, from, to time.Time) Totals {
+	t := Totals{}
+	for _, event := range events {
+		if event.Created.Before(from) || !event.Created.Before(to) {
+			continue
+		}
+		switch event.Kind {
+		case database.MemberJoined:
+			t.Joins++
+		case database.MemberRegistered:
+			t.Registrations++
+		case database.MemberLeft:
+			t.Leaves++
+		case database.MemberRegistrationFailed:
+			t.FailedRegistrations++
+		}
+	}
+	return t
+}
+
+func weekly(events []*database.MemberEvent, kind string, from, to time.Time) []Count {
+	counts := []Count{}
+	for week := startOfWeek(from); week.Before(to); week = week.AddDate(0, 0, 7) {
+		end := week.AddDate(0, 0, 7)
+		count := 0
+		for _, event := range events {
+			if event.Kind == kind && !event.Created.Before(week) && event.Created.Before(end) {
+				count++
+			}
+		}
+		counts = append(counts, Count{Date: week, Count: count})
+	}
+	return counts
+}
+
+// location days and weeks start in, which is the bot's timezone
+func location() *time.Location {
+	return config.Location()
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.In(location())
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// startOfWeek returns the start of the Monday of t's week
+func startOfWeek(t time.Time) time.Time {
+	day := startOfDay(t)
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
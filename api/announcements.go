@@ -9,15 +9,27 @@ import (
 	"github.com/spf13/viper"
 )
 
+// PreviewFlag is given to commands to preview them before they're published
+const PreviewFlag = "--preview"
+
 // Announcement for bot and rest api
 type Announcement struct {
 	Date    time.Time
 	Content string
-	*Image
+	Images  []*Image
 }
 
 // ParseAnnouncement Return an annoucement from a message
 func ParseAnnouncement(m *discordgo.MessageCreate, help string) (*Announcement, error) {
+	return parseAnnouncement(m.Message, help, true)
+}
+
+// ReadAnnouncement parses an announcement which has already been posted, skipping any of its images which aren't valid
+func ReadAnnouncement(m *discordgo.Message) (*Announcement, error) {
+	return parseAnnouncement(m, "", false)
+}
+
+func parseAnnouncement(m *discordgo.Message, help string, strict bool) (*Announcement, error) {
 	// In the correct channel
 	var content string
 	if strings.HasPrefix(m.Content, viper.GetString("bot.prefix")+"announce") {
@@ -27,6 +39,7 @@ func ParseAnnouncement(m *discordgo.MessageCreate, help string) (*Announcement,
 		content = strings.TrimPrefix(m.Content, viper.GetString("bot.prefix")+"sannounce")
 		content = strings.Trim(content, " ")
 	}
+	content = strings.TrimSpace(strings.TrimPrefix(content, PreviewFlag))
 	if len(content) == 0 {
 		return nil, fmt.Errorf("Error parsing command\n```%s```", help)
 	}
@@ -34,13 +47,13 @@ func ParseAnnouncement(m *discordgo.MessageCreate, help string) (*Announcement,
 	if err != nil {
 		return nil, fmt.Errorf("Error converting date: %w", err)
 	}
-	img, err := parseImage(m.Message)
+	imgs, err := parseImages(m, strict)
 	if err != nil {
 		return nil, err
 	}
 	return &Announcement{
 		Date:    date,
 		Content: content,
-		Image:   img,
+		Images:  imgs,
 	}, nil
 }
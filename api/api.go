@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"sort"
@@ -11,21 +12,32 @@ import (
 
 	"github.com/Strum355/log"
 	"github.com/UCCNetsoc/discord-bot/config"
+	"github.com/UCCNetsoc/discord-bot/database"
+	"github.com/UCCNetsoc/discord-bot/images"
+	"github.com/UCCNetsoc/discord-bot/media"
+	"github.com/UCCNetsoc/discord-bot/prometheus"
+	"github.com/UCCNetsoc/discord-bot/server"
 	"github.com/bwmarrin/discordgo"
 	"github.com/patrickmn/go-cache"
 	"github.com/spf13/viper"
 )
 
 type returnEvent struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	ImageURL    string `json:"image_url"`
-	Date        int64  `json:"date"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description"`
+	Location     string   `json:"location,omitempty"`
+	Tags         []string `json:"tags"`
+	ImageURL     string   `json:"image_url"`
+	ImageURLs    []string `json:"image_urls"`
+	ThumbnailURL string   `json:"thumbnail_url"`
+	Date         int64    `json:"date"`
 }
 type returnAnnouncement struct {
-	Date     int64  `json:"date"`
-	Content  string `json:"content"`
-	ImageURL string `json:"image_url"`
+	Date         int64    `json:"date"`
+	Content      string   `json:"content"`
+	ImageURL     string   `json:"image_url"`
+	ImageURLs    []string `json:"image_urls"`
+	ThumbnailURL string   `json:"thumbnail_url,omitempty"`
 }
 type returnMembers struct {
 	Count int `json:"count"`
@@ -46,16 +58,20 @@ func (e *sortEvents) Swap(i, j int) {
 	(*e)[j] = temp
 }
 
-// Run the REST API
+// Run registers the REST API's routes, which are served once the server is started
 func Run(s *discordgo.Session) {
 	cached = cache.New(3*time.Minute, 3*time.Minute)
 	session = s
 
-	http.HandleFunc("/events", getEvents)
-	http.HandleFunc("/announcements", getAnnouncements)
-	http.HandleFunc("/getMembers", getMembers)
-
-	http.ListenAndServe(fmt.Sprintf(":%d", viper.GetInt("api.port")), nil)
+	addr := fmt.Sprintf(":%d", viper.GetInt("api.port"))
+	server.HandleFunc(addr, "/events", prometheus.InstrumentHandler("/events", getEvents))
+	server.HandleFunc(addr, "/announcements", prometheus.InstrumentHandler("/announcements", getAnnouncements))
+	server.HandleFunc(addr, "/getMembers", prometheus.InstrumentHandler("/getMembers", getMembers))
+	server.HandleFunc(addr, media.Path, prometheus.InstrumentHandler(media.Path, getMedia))
+	server.HandleFunc(addr, "/stats/members", prometheus.InstrumentHandler("/stats/members", statsOnly(getMemberStats)))
+	server.HandleFunc(addr, "/stats/registrations", prometheus.InstrumentHandler("/stats/registrations", statsOnly(getRegistrationStats)))
+	server.HandleFunc(addr, "/stats/channels", prometheus.InstrumentHandler("/stats/channels", statsOnly(getChannelStats)))
+	server.HandleFunc(addr, "/voice", prometheus.InstrumentHandler("/voice", statsOnly(getVoice)))
 }
 
 func getEvents(w http.ResponseWriter, r *http.Request) {
@@ -76,8 +92,48 @@ func getEvents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	events, err := UpcomingEvents(strings.ToLower(query.Get("tag")))
+	if err != nil {
+		log.WithError(err).Error("Error querying events for api")
+		return
+	}
+	if len(events) > amount {
+		events = events[:amount]
+	}
+	w.Header().Set("content-type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	returnEvents := []returnEvent{}
+	for _, event := range events {
+		returned := returnEvent{
+			Title:       event.Title,
+			Description: event.Description,
+			Location:    event.Location,
+			Tags:        event.Tags,
+			ImageURLs:   imageURLs(event.Images),
+			Date:        event.Date.Unix(),
+		}
+		// Events whose images were all invalid are still shown, just without them
+		if len(returned.ImageURLs) > 0 {
+			returned.ImageURL = returned.ImageURLs[0]
+			returned.ThumbnailURL = thumbnailURL(event.Images[0])
+		}
+		returnEvents = append(returnEvents, returned)
+	}
+
+	b, err := json.Marshal(returnEvents)
+	if err != nil {
+		log.WithFields(log.Fields{"events": returnEvents}).WithError(err).Error("Error marshalling events")
+		return
+	}
+	w.Write(b)
+}
+
+// UpcomingEvents returns the published events which haven't happened yet, soonest first.
+// Only events with the tag are returned if it isn't empty
+func UpcomingEvents(tag string) ([]*Event, error) {
 	var events []*Event
 	cachedEvents, found := cached.Get("events")
+	prometheus.CacheLookup("events", found)
 	if found {
 		events = cachedEvents.([]*Event)
 	} else {
@@ -85,50 +141,27 @@ func getEvents(w http.ResponseWriter, r *http.Request) {
 		channelID := viper.Get("discord.channels").(*config.Channels).PrivateEvents
 		liveEvents, err := session.ChannelMessages(channelID, 100, "", "", "")
 		if err != nil {
-			log.WithError(err).Error("Error querying events for api")
-			return
+			return nil, err
 		}
 		for _, event := range liveEvents {
-			parsed, err := ParseEvent(&discordgo.MessageCreate{Message: event}, "")
-			if err == nil {
+			parsed, err := ReadEvent(event)
+			if err == nil && published(event.ID) {
 				// Message successfully parsed as an event.
 				events = append(events, parsed)
 			}
 		}
 		cached.Set("events", events, cache.DefaultExpiration)
 	}
-	// Filter out events that have already passed
-	allEvents := make([]*Event, len(events))
-	copy(allEvents, events)
-	events = []*Event{}
-	for _, event := range allEvents {
-		if event.Date.Unix() > time.Now().AddDate(0, 0, 0).Unix() {
-			events = append(events, event)
+	// Filter out events that have already passed, and those without the requested tag
+	upcoming := []*Event{}
+	for _, event := range events {
+		if event.Date.Unix() > time.Now().Unix() && (tag == "" || event.HasTag(tag)) {
+			upcoming = append(upcoming, event)
 		}
 	}
-	sortE := sortEvents(events)
+	sortE := sortEvents(upcoming)
 	sort.Sort(&sortE)
-	if len(events) > amount {
-		events = events[:amount]
-	}
-	w.Header().Set("content-type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	returnEvents := []returnEvent{}
-	for _, event := range events {
-		returnEvents = append(returnEvents, returnEvent{
-			event.Title,
-			event.Description,
-			event.ImgURL,
-			event.Date.Unix(),
-		})
-	}
-
-	b, err := json.Marshal(returnEvents)
-	if err != nil {
-		log.WithFields(log.Fields{"events": returnEvents}).WithError(err).Error("Error marshalling events")
-		return
-	}
-	w.Write(b)
+	return upcoming, nil
 }
 
 type sortAnnouncements []*Announcement
@@ -161,6 +194,7 @@ func getAnnouncements(w http.ResponseWriter, r *http.Request) {
 
 	var announcements []*Announcement
 	cachedAnnouncements, found := cached.Get("announcements")
+	prometheus.CacheLookup("announcements", found)
 	if found {
 		announcements = cachedAnnouncements.([]*Announcement)
 	} else {
@@ -179,8 +213,8 @@ func getAnnouncements(w http.ResponseWriter, r *http.Request) {
 		}
 		// Get messages from private command channel
 		for _, event := range privateAnnounce {
-			parsed, err := ParseAnnouncement(&discordgo.MessageCreate{Message: event}, "")
-			if err == nil {
+			parsed, err := ReadAnnouncement(event)
+			if err == nil && published(event.ID) {
 				// Message successfully parsed as an event.
 				announcements = append(announcements, parsed)
 			}
@@ -193,11 +227,7 @@ func getAnnouncements(w http.ResponseWriter, r *http.Request) {
 					log.WithError(err).Error("Message time parse fail")
 					return
 				}
-				img, err := parseImage(message)
-				if err != nil {
-					log.WithError(err).Error("Message image parse fail")
-					return
-				}
+				imgs, _ := parseImages(message, false)
 				content, err := message.ContentWithMoreMentionsReplaced(session)
 				for _, symbol := range viper.GetStringSlice("api.remove_symbols") {
 					content = strings.ReplaceAll(content, symbol, "")
@@ -210,7 +240,7 @@ func getAnnouncements(w http.ResponseWriter, r *http.Request) {
 				announcement := &Announcement{
 					Date:    date,
 					Content: content,
-					Image:   img,
+					Images:  imgs,
 				}
 				announcements = append(announcements, announcement)
 			}
@@ -227,11 +257,13 @@ func getAnnouncements(w http.ResponseWriter, r *http.Request) {
 	returnAnnouncements := []returnAnnouncement{}
 	for _, ann := range announcements {
 		announce := returnAnnouncement{
-			Date:    ann.Date.Unix(),
-			Content: ann.Content,
+			Date:      ann.Date.Unix(),
+			Content:   ann.Content,
+			ImageURLs: imageURLs(ann.Images),
 		}
-		if ann.ImgData != nil {
-			announce.ImageURL = ann.ImgURL
+		if len(announce.ImageURLs) > 0 {
+			announce.ImageURL = announce.ImageURLs[0]
+			announce.ThumbnailURL = thumbnailURL(ann.Images[0])
 		}
 		returnAnnouncements = append(returnAnnouncements, announce)
 	}
@@ -259,3 +291,32 @@ func getMembers(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(returnMembers{Count: len(members)})
 }
+
+// published reports whether a command has been published, rather than waiting on a preview or approval
+func published(commandID string) bool {
+	draft, err := database.GetDraftByCommand(commandID)
+	if errors.Is(err, database.ErrNotFound) {
+		return true
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to get draft")
+		return false
+	}
+	return draft.Status == database.DraftPublished
+}
+
+func imageURLs(imgs []*Image) []string {
+	urls := []string{}
+	for _, img := range imgs {
+		urls = append(urls, img.ImgURL)
+	}
+	return urls
+}
+
+// thumbnailURL returns the url of the thumbnail variant, or the image itself if it isn't in the media store
+func thumbnailURL(img *Image) string {
+	if img.MediaID == "" {
+		return img.ImgURL
+	}
+	return img.ImgURL + "?size=" + images.Thumbnail
+}
@@ -1,13 +1,11 @@
 package api
 
 import (
-	"bytes"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"strings"
 	"time"
 
+	"github.com/UCCNetsoc/discord-bot/config"
 	"github.com/bwmarrin/discordgo"
 )
 
@@ -15,49 +13,90 @@ import (
 type Event struct {
 	Title,
 	Description string
-	Date time.Time
-	*Image
+	Date     time.Time
+	Location string
+	Tags     []string
+	Images   []*Image
 }
 
-const layoutISO = "2006-01-02"
+const (
+	layoutISO     = "2006-01-02"
+	layoutISOTime = "2006-01-02 15:04"
+)
 
 // ParseEvent will give an event object
+// Location and a comma separated list of tags are optional and may follow the description
 func ParseEvent(m *discordgo.MessageCreate, help string) (*Event, error) {
+	return parseEvent(m.Message, help, true)
+}
+
+// ReadEvent parses an event which has already been posted, skipping any of its images which aren't valid
+func ReadEvent(m *discordgo.Message) (*Event, error) {
+	return parseEvent(m, "", false)
+}
+
+func parseEvent(m *discordgo.Message, help string, strict bool) (*Event, error) {
 	// In the correct channel
 	params := strings.Split(m.Content, "\"")
-	if len(params) != 7 {
+	if len(params) != 7 && len(params) != 9 && len(params) != 11 {
 		return nil, fmt.Errorf("Error parsing command\n```%s```", help)
 	}
-	if len(m.Attachments) != 1 || m.Attachments[0].Width == 0 {
-		return nil, fmt.Errorf("No image attached")
-	}
 	title := params[1]
 	date := params[3]
 	description := params[5]
-	dateTime, err := time.Parse(layoutISO, date)
+	dateTime, err := parseDate(date)
 	if err != nil {
-		return nil, fmt.Errorf("Error parsing date. Should be in the format yyyy-mm-dd")
+		return nil, fmt.Errorf("Error parsing date. Should be in the format yyyy-mm-dd or yyyy-mm-dd hh:mm")
 	}
-	image := m.Attachments[0]
-	imageReader, err := http.Get(image.URL)
-	if err != nil {
-		return nil, fmt.Errorf("Error parsing image: %w", err)
+	var location string
+	if len(params) > 7 {
+		location = strings.TrimSpace(params[7])
 	}
-	defer imageReader.Body.Close()
-	imageRead, err := ioutil.ReadAll(imageReader.Body)
+	tags := []string{}
+	if len(params) > 9 {
+		for _, tag := range strings.Split(params[9], ",") {
+			if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	imgs, err := parseImages(m, strict)
 	if err != nil {
 		return nil, err
 	}
-	imageBody := bytes.NewBuffer(imageRead)
+	if len(imgs) == 0 && strict {
+		return nil, fmt.Errorf("No image attached")
+	}
 
 	return &Event{
 		Title:       title,
 		Description: description,
 		Date:        dateTime,
-		Image: &Image{
-			ImgData:   imageBody,
-			ImgURL:    imageReader.Request.URL.String(),
-			ImgHeader: &imageReader.Header,
-		},
+		Location:    location,
+		Tags:        tags,
+		Images:      imgs,
 	}, nil
 }
+
+// HasTag reports whether the event was tagged with tag
+func (e *Event) HasTag(tag string) bool {
+	for _, t := range e.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// HasTime reports whether the event was given a start time, rather than just a date
+func (e *Event) HasTime() bool {
+	return e.Date.Hour() != 0 || e.Date.Minute() != 0
+}
+
+func parseDate(date string) (time.Time, error) {
+	loc := config.Location()
+	if dateTime, err := time.ParseInLocation(layoutISOTime, date, loc); err == nil {
+		return dateTime, nil
+	}
+	return time.ParseInLocation(layoutISO, date, loc)
+}
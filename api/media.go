@@ -0,0 +1,57 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Strum355/log"
+	"github.com/UCCNetsoc/discord-bot/database"
+	"github.com/UCCNetsoc/discord-bot/images"
+	"github.com/UCCNetsoc/discord-bot/media"
+)
+
+// getMedia serves images from the media store
+// The size parameter selects a resized variant, either web or thumbnail
+func getMedia(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, media.Path)
+	if !media.ValidID(id) {
+		http.NotFound(w, r)
+		return
+	}
+	variant := r.URL.Query().Get("size")
+	switch variant {
+	case "", images.Original, images.Web, images.Thumbnail:
+	default:
+		http.Error(w, "Please provide either web or thumbnail as 'size's value", 403)
+		return
+	}
+
+	// Content never changes for a given address so the id doubles as the etag
+	etag := fmt.Sprintf("\"%s-%s\"", id, variant)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	data, err := media.LoadVariant(id, variant)
+	if errors.Is(err, os.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"media_id": id, "size": variant}).Error("Failed to load media")
+		http.Error(w, "Failed to load media", 500)
+		return
+	}
+	w.Header().Set("content-type", images.DetectType(data))
+	if stored, err := database.GetMedia(id); err == nil && (variant == "" || variant == images.Original) {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", stored.Filename))
+	}
+	w.Write(data)
+}
@@ -2,24 +2,49 @@ package api
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"path"
 
+	"github.com/Strum355/log"
+	"github.com/UCCNetsoc/discord-bot/database"
+	"github.com/UCCNetsoc/discord-bot/images"
+	"github.com/UCCNetsoc/discord-bot/media"
 	"github.com/bwmarrin/discordgo"
+	"github.com/spf13/viper"
 )
 
 // Entry represents an entry in the announcements channel
 type Entry interface {
 	GetContent() string
-	GetImage() *Image
+	GetImages() []*Image
 }
 
 // Image to be embedded in an entry
 type Image struct {
 	ImgData   *bytes.Buffer
 	ImgHeader *http.Header
-	ImgURL    string
+	ImgURL    string // Stable url served from the media store, or the discord url if it isn't served
+	SourceURL string // Discord CDN url of the attachment
+	MediaID   string
+	Filename  string
+}
+
+// ContentType of the image data
+func (i *Image) ContentType() string {
+	return images.DetectType(i.ImgData.Bytes())
+}
+
+// File for sending the image to discord
+func (i *Image) File() *discordgo.File {
+	return &discordgo.File{
+		Name:        i.Filename,
+		ContentType: i.ContentType(),
+		Reader:      bytes.NewReader(i.ImgData.Bytes()),
+	}
 }
 
 // GetContent returns message content
@@ -32,41 +57,151 @@ func (e Event) GetContent() string {
 	return e.Description
 }
 
-// GetImage returns Image response data
-func (a Announcement) GetImage() *Image {
-	return a.Image
+// GetImages returns Image response data
+func (a Announcement) GetImages() []*Image {
+	return a.Images
 }
 
-// GetImage returns Image response data
-func (e Event) GetImage() *Image {
-	return e.Image
+// GetImages returns Image response data
+func (e Event) GetImages() []*Image {
+	return e.Images
 }
 
-func parseImage(m *discordgo.Message) (*Image, error) {
-	var (
-		image       *http.Response
-		err         error
-		imageHeader *http.Header
-		imageURL    string
-		imageBody   *bytes.Buffer
-	)
-	if len(m.Attachments) > 0 && m.Attachments[0].Width > 0 {
-		image, err = http.Get(m.Attachments[0].URL)
-		if err != nil {
-			return nil, fmt.Errorf("Error parsing image: %w", err)
+// parseImages downloads and validates every image attached to the message
+// Images are copied into the media store the first time they're seen so later calls don't download them again.
+// If strict is false, as for entries which have already been posted, images which aren't valid are skipped rather than failing
+func parseImages(m *discordgo.Message, strict bool) ([]*Image, error) {
+	imgs := []*Image{}
+	for _, attachment := range m.Attachments {
+		if attachment.Width == 0 {
+			// Not an image
+			continue
+		}
+		if len(imgs) == viper.GetInt("images.max_count") {
+			if !strict {
+				break
+			}
+			return nil, fmt.Errorf("Too many images, the limit is %d", viper.GetInt("images.max_count"))
 		}
-		imageHeader = &image.Header
-		imageURL = image.Request.URL.String()
-		imageRead, err := ioutil.ReadAll(image.Body)
+		img, err := parseImage(attachment)
 		if err != nil {
+			if !strict {
+				log.WithError(err).WithFields(log.Fields{"message_id": m.ID}).Warn("Skipping invalid image")
+				continue
+			}
 			return nil, err
 		}
-		imageBody = bytes.NewBuffer(imageRead)
-		defer image.Body.Close()
+		imgs = append(imgs, img)
+	}
+	return imgs, nil
+}
+
+// parseImage loads the attachment from the media store, or downloads, validates and stores it if it hasn't been seen
+func parseImage(attachment *discordgo.MessageAttachment) (*Image, error) {
+	if img := storedImage(attachment); img != nil {
+		return img, nil
+	}
+	if err := images.Validate(mime.TypeByExtension(path.Ext(attachment.Filename)), attachment.Size); err != nil {
+		return nil, fmt.Errorf("%s: %w", attachment.Filename, err)
+	}
+	img, err := FetchImage(attachment.URL)
+	if err != nil {
+		return nil, err
+	}
+	// Extensions can lie, check the data too
+	if err := images.Validate(img.ContentType(), img.ImgData.Len()); err != nil {
+		return nil, fmt.Errorf("%s: %w", attachment.Filename, err)
+	}
+	img.Filename = attachment.Filename
+	storeImage(attachment, img)
+	return img, nil
+}
+
+// storedImage loads an attachment from the media store, returning nil if it hasn't been stored
+func storedImage(attachment *discordgo.MessageAttachment) *Image {
+	stored, err := database.GetMediaByAttachment(attachment.ID)
+	if err != nil {
+		if !errors.Is(err, database.ErrNotFound) {
+			log.WithError(err).Error("Failed to look up stored media")
+		}
+		return nil
+	}
+	data, err := media.Load(stored.ID)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"media_id": stored.ID}).Error("Failed to load stored media")
+		return nil
+	}
+	img := &Image{
+		ImgData:   bytes.NewBuffer(data),
+		ImgURL:    attachment.URL,
+		SourceURL: attachment.URL,
+		Filename:  stored.Filename,
+	}
+	useMedia(img, stored.ID)
+	return img
+}
+
+// storeImage copies the image into the media store and points it at its stable url
+// The discord url is kept if it can't be stored
+func storeImage(attachment *discordgo.MessageAttachment, img *Image) {
+	id, err := media.Store(img.ImgData.Bytes())
+	if err != nil {
+		log.WithError(err).Error("Failed to store media")
+		return
+	}
+	err = database.SaveMedia(&database.Media{
+		AttachmentID: attachment.ID,
+		ID:           id,
+		Filename:     attachment.Filename,
+		ContentType:  img.ContentType(),
+		Size:         img.ImgData.Len(),
+	})
+	if err != nil {
+		log.WithError(err).Error("Failed to record stored media")
+		return
+	}
+	useMedia(img, id)
+}
+
+// useMedia points the image at its copy in the media store, if the media store is served
+func useMedia(img *Image, id string) {
+	if !media.Served() {
+		return
+	}
+	img.ImgURL = media.URL(id)
+	img.MediaID = id
+}
+
+// FetchImage downloads the image at the given url, reading it from the media store if it's one of ours
+func FetchImage(url string) (*Image, error) {
+	if id, ok := media.IDFromURL(url); ok {
+		data, err := media.Load(id)
+		if err != nil {
+			return nil, fmt.Errorf("Error loading image: %w", err)
+		}
+		return &Image{
+			ImgData: bytes.NewBuffer(data),
+			ImgURL:  url,
+			MediaID: id,
+		}, nil
+	}
+	image, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing image: %w", err)
+	}
+	defer image.Body.Close()
+	if image.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error fetching image: %s", image.Status)
+	}
+	imageRead, err := ioutil.ReadAll(image.Body)
+	if err != nil {
+		return nil, err
 	}
 	return &Image{
-		ImgData:   imageBody,
-		ImgURL:    imageURL,
-		ImgHeader: imageHeader,
+		ImgData:   bytes.NewBuffer(imageRead),
+		ImgURL:    image.Request.URL.String(),
+		SourceURL: image.Request.URL.String(),
+		ImgHeader: &image.Header,
+		Filename:  path.Base(image.Request.URL.Path),
 	}, nil
 }
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Strum355/log"
+	"github.com/UCCNetsoc/discord-bot/analytics"
+	"github.com/UCCNetsoc/discord-bot/config"
+	"github.com/UCCNetsoc/discord-bot/database"
+	"github.com/spf13/viper"
+)
+
+type returnCount struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+type returnChannel struct {
+	ChannelID string `json:"channel_id"`
+	Name      string `json:"name"`
+	Messages  int    `json:"messages"`
+}
+
+// getMemberStats returns how many members the server had on each of the past 'days' days
+func getMemberStats(w http.ResponseWriter, r *http.Request) {
+	days, ok := statsParam(w, r, "days", 30, 365)
+	if !ok {
+		return
+	}
+	current, err := memberCount()
+	if err != nil {
+		log.WithError(err).Error("Failed to get member count for stats")
+		http.Error(w, "Failed to get members", 500)
+		return
+	}
+	counts, err := analytics.MembersOverTime(current, time.Now().AddDate(0, 0, -days+1), time.Now())
+	if err != nil {
+		log.WithError(err).Error("Failed to get members over time")
+		http.Error(w, "Failed to get stats", 500)
+		return
+	}
+	writeStats(w, returnCounts(counts))
+}
+
+// getRegistrationStats returns how many members registered in each of the past 'weeks' weeks
+func getRegistrationStats(w http.ResponseWriter, r *http.Request) {
+	weeks, ok := statsParam(w, r, "weeks", 12, 104)
+	if !ok {
+		return
+	}
+	counts, err := analytics.RegistrationsPerWeek(time.Now().AddDate(0, 0, -7*(weeks-1)), time.Now())
+	if err != nil {
+		log.WithError(err).Error("Failed to get registrations per week")
+		http.Error(w, "Failed to get stats", 500)
+		return
+	}
+	writeStats(w, returnCounts(counts))
+}
+
+// getChannelStats returns the 'limit' channels with the most messages in the past 'days' days
+func getChannelStats(w http.ResponseWriter, r *http.Request) {
+	days, ok := statsParam(w, r, "days", 30, 365)
+	if !ok {
+		return
+	}
+	limit, ok := statsParam(w, r, "limit", 10, 50)
+	if !ok {
+		return
+	}
+	servers := viper.Get("discord.servers").(*config.Servers)
+	activities, err := database.ChannelActivities(servers.PublicServer, time.Now().AddDate(0, 0, -days+1), time.Now(), limit)
+	if err != nil {
+		log.WithError(err).Error("Failed to get channel activity")
+		http.Error(w, "Failed to get stats", 500)
+		return
+	}
+	channels := []returnChannel{}
+	for _, activity := range activities {
+		channel := returnChannel{ChannelID: activity.Channel, Messages: activity.Messages}
+		if c, err := session.State.Channel(activity.Channel); err == nil {
+			channel.Name = c.Name
+		}
+		channels = append(channels, channel)
+	}
+	writeStats(w, channels)
+}
+
+// statsParam reads a positive int query parameter, replying with an error if it's invalid
+func statsParam(w http.ResponseWriter, r *http.Request, name string, fallback, max int) (int, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		http.Error(w, "Please provide a positive int as '"+name+"'s value", 403)
+		return 0, false
+	}
+	if n > max {
+		http.Error(w, "'"+name+"' exceeds the limit of "+strconv.Itoa(max), 403)
+		return 0, false
+	}
+	return n, true
+}
+
+// statsOnly only lets requests with the stats token through, unless the stats have been made public
+func statsOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !viper.GetBool("api.stats_public") {
+			token := viper.GetString("api.stats_token")
+			if token == "" {
+				http.Error(w, "Stats are unavailable as no stats token is configured", 403)
+				return
+			}
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+				http.Error(w, "Invalid or missing stats token", 401)
+				return
+			}
+		}
+		h(w, r)
+	}
+}
+
+// memberCount returns how many users are in the public server
+func memberCount() (int, error) {
+	servers := viper.Get("discord.servers").(*config.Servers)
+	guild, err := session.State.Guild(servers.PublicServer)
+	if err != nil {
+		return 0, err
+	}
+	return guild.MemberCount, nil
+}
+
+func returnCounts(counts []analytics.Count) []returnCount {
+	returned := []returnCount{}
+	for _, count := range counts {
+		returned = append(returned, returnCount{Date: count.Date.Format("2006-01-02"), Count: count.Count})
+	}
+	return returned
+}
+
+func writeStats(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("content-type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(body)
+}
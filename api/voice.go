@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Strum355/log"
+	"github.com/UCCNetsoc/discord-bot/activity"
+	"github.com/UCCNetsoc/discord-bot/database"
+	"github.com/UCCNetsoc/discord-bot/voice"
+)
+
+type returnVoiceMember struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type returnVoiceChannel struct {
+	ChannelID string              `json:"channel_id"`
+	Name      string              `json:"name"`
+	Count     int                 `json:"count"`
+	Members   []returnVoiceMember `json:"members"` // Only members whose activity is tracked are listed
+}
+
+type returnVoiceUsage struct {
+	ChannelID string `json:"channel_id"`
+	Name      string `json:"name"`
+	Minutes   int    `json:"minutes"`
+	Sessions  int    `json:"sessions"`
+}
+
+type returnVoice struct {
+	Channels []returnVoiceChannel `json:"channels"`
+	Usage    []returnVoiceUsage   `json:"usage"`
+}
+
+// getVoice returns who is in each voice channel and how much each was used in the past 'days' days, a week by default
+// Members are only named if their activity is tracked, so those who opted out aren't published
+func getVoice(w http.ResponseWriter, r *http.Request) {
+	days, ok := statsParam(w, r, "days", 7, 365)
+	if !ok {
+		return
+	}
+	channels, err := voice.Channels(session)
+	if err != nil {
+		log.WithError(err).Error("Failed to get voice channels")
+		http.Error(w, "Failed to get voice channels", 500)
+		return
+	}
+	usages, err := database.VoiceUsages(time.Now().AddDate(0, 0, -days), time.Now())
+	if err != nil {
+		log.WithError(err).Error("Failed to get voice usage")
+		http.Error(w, "Failed to get voice usage", 500)
+		return
+	}
+
+	returned := returnVoice{Channels: []returnVoiceChannel{}, Usage: []returnVoiceUsage{}}
+	for _, channel := range channels {
+		c := returnVoiceChannel{ChannelID: channel.ID, Name: channel.Name, Count: len(channel.Members), Members: []returnVoiceMember{}}
+		for _, member := range channel.Members {
+			if !activity.Tracked(member.User.ID) {
+				continue
+			}
+			c.Members = append(c.Members, returnVoiceMember{ID: member.User.ID, Name: voice.Name(member)})
+		}
+		returned.Channels = append(returned.Channels, c)
+	}
+	for _, usage := range usages {
+		u := returnVoiceUsage{ChannelID: usage.Channel, Minutes: usage.Seconds / 60, Sessions: usage.Sessions}
+		if c, err := session.State.Channel(usage.Channel); err == nil {
+			u.Name = c.Name
+		}
+		returned.Usage = append(returned.Usage, u)
+	}
+	writeStats(w, returned)
+}
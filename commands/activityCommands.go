@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Strum355/log"
+	"github.com/UCCNetsoc/discord-bot/activity"
+	"github.com/UCCNetsoc/discord-bot/database"
+	"github.com/UCCNetsoc/discord-bot/embed"
+	"github.com/bwmarrin/discordgo"
+	"github.com/spf13/viper"
+)
+
+// top shows the members who sent the most messages, optionally only in one channel
+func top(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
+	if !activity.Enabled() {
+		s.ChannelMessageSend(m.ChannelID, "Activity tracking is turned off")
+		return
+	}
+	_, body := extractCommand(m.Content)
+	period := 7 * 24 * time.Hour
+	channel := ""
+	for _, arg := range strings.Fields(body)[1:] {
+		if matches := channelMentionRegex.FindStringSubmatch(arg); matches != nil {
+			channel = matches[1]
+		} else if duration, ok := parseDuration(arg); ok && duration >= 24*time.Hour {
+			period = duration
+		} else {
+			s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Please give a channel and a period of at least a day such as 7d or 4w\n"+helpStrings["top"]))
+			return
+		}
+	}
+
+	totals, err := database.TopUsers(time.Now().Add(-period), channel, 10)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("failed to get leaderboard")
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Failed to get the leaderboard."))
+		return
+	}
+	description := fmt.Sprintf("Most messages in the last %s", formatAge(period))
+	if channel != "" {
+		description += fmt.Sprintf(" in <#%s>", channel)
+	}
+	lines := []string{}
+	for i, total := range totals {
+		lines = append(lines, fmt.Sprintf("%d. <@%s>: %d messages", i+1, total.UserID, total.Messages))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "Nobody has sent any messages yet")
+	}
+	footer := "Don't want to be included? Use !optout"
+	if viper.GetBool("activity.opt_in") {
+		footer = "Only members who use !optin are included"
+	}
+	emb := embed.NewEmbed().
+		SetTitle("Leaderboard").
+		SetDescription(description + "\n\n" + strings.Join(lines, "\n")).
+		SetColor(0xFF9800).
+		SetFooter(footer)
+	s.ChannelMessageSendEmbed(m.ChannelID, emb.MessageEmbed)
+}
+
+// mystats shows the activity of the member who asked
+func mystats(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
+	if !activity.Enabled() {
+		s.ChannelMessageSend(m.ChannelID, "Activity tracking is turned off")
+		return
+	}
+	if !activity.Tracked(m.Author.ID) {
+		s.ChannelMessageSend(m.ChannelID, "Your activity isn't being tracked, use *`!optin`* if you'd like it to be")
+		return
+	}
+	_, body := extractCommand(m.Content)
+	period := 30 * 24 * time.Hour
+	if args := strings.Fields(body)[1:]; len(args) > 0 {
+		duration, ok := parseDuration(args[0])
+		if !ok || duration < 24*time.Hour {
+			s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Please give a period of at least a day such as 7d or 4w\n"+helpStrings["mystats"]))
+			return
+		}
+		period = duration
+	}
+
+	from := time.Now().Add(-period)
+	total, rank, err := database.UserTotals(m.Author.ID, from)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("failed to get user stats")
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Failed to get your stats."))
+		return
+	}
+	channels, err := database.UserChannels(m.Author.ID, from, 3)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("failed to get user channels")
+	}
+
+	emb := embed.NewEmbed().
+		SetTitle("Your Stats").
+		SetAuthor(m.Author.String(), m.Author.AvatarURL("")).
+		SetDescription(fmt.Sprintf("Your activity in the last %s", formatAge(period))).
+		SetColor(0xFF9800).
+		AddField("Messages", fmt.Sprint(total.Messages))
+	if total.Messages > 0 {
+		emb.AddField("Rank", fmt.Sprintf("#%d", rank))
+	}
+	if total.VoiceSeconds > 0 {
+		emb.AddField("Time in Voice", (time.Duration(total.VoiceSeconds) * time.Second).String())
+	}
+	lines := []string{}
+	for _, channel := range channels {
+		lines = append(lines, fmt.Sprintf("<#%s>: %d messages", channel.Channel, channel.Messages))
+	}
+	if len(lines) > 0 {
+		emb.AddField("Favourite Channels", strings.Join(lines, "\n"))
+	}
+	s.ChannelMessageSendEmbed(m.ChannelID, emb.MessageEmbed)
+}
+
+// optout stops tracking the member's activity and deletes what has been tracked
+func optout(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
+	setTracked(ctx, s, m, false, "Your activity is no longer tracked and what was tracked has been deleted. Use *`!optin`* to be tracked again")
+}
+
+// optin tracks the member's activity
+func optin(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
+	setTracked(ctx, s, m, true, "Your activity is now tracked for *`!top`* and *`!mystats`*. Use *`!optout`* to stop")
+}
+
+func setTracked(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, tracked bool, reply string) {
+	if !activity.Enabled() {
+		s.ChannelMessageSend(m.ChannelID, "Activity tracking is turned off")
+		return
+	}
+	if err := activity.SetTracked(m.Author.ID, tracked); err != nil {
+		log.WithContext(ctx).WithError(err).Error("failed to set activity preference")
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Failed to save your choice, please try again."))
+		return
+	}
+	s.ChannelMessageSend(m.ChannelID, reply)
+}
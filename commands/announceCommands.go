@@ -1,15 +1,15 @@
 package commands
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"io"
+	"strconv"
 	"strings"
 
 	"github.com/Strum355/log"
 	"github.com/UCCNetsoc/discord-bot/api"
 	"github.com/UCCNetsoc/discord-bot/config"
+	"github.com/UCCNetsoc/discord-bot/embed"
 	"github.com/UCCNetsoc/discord-bot/prometheus"
 	"github.com/bwmarrin/discordgo"
 	"github.com/spf13/viper"
@@ -26,13 +26,17 @@ func addEventSilent(ctx context.Context, s *discordgo.Session, m *discordgo.Mess
 func addEventWebsite(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
 	channels := viper.Get("discord.channels").(*config.Channels)
 	if isCommittee(s, m) && m.ChannelID == channels.PrivateEvents {
-		_, err := api.ParseEvent(m, committeeHelpStrings["event"])
+		event, err := api.ParseEvent(m, committeeHelpStrings["event"])
 		if err != nil {
 			log.WithContext(ctx).WithError(err).Error("failed to parse event")
 			s.ChannelMessageSend(m.ChannelID, "Failed to parse event: "+err.Error())
 			return
 		}
-		s.ChannelMessageSend(m.ChannelID, "Event successfully posted to website! (Depending on cache may take a few minutes)")
+		if previewRequested(m) {
+			createDraft(ctx, s, m, eventMessage(event, "everyone"), event, 0)
+			return
+		}
+		publishEventWebsite(s, m)
 	} else {
 		s.ChannelMessageSend(m.ChannelID, "This command is unavailable")
 	}
@@ -47,29 +51,123 @@ func event(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate
 			s.ChannelMessageSend(m.ChannelID, "Failed to parse event: "+err.Error())
 			return
 		}
-		b := bytes.NewBuffer([]byte{})
-		s.ChannelFileSendWithMessage(
-			channels.PublicAnnouncements,
-			fmt.Sprintf(
+		if previewRequested(m) {
+			createDraft(ctx, s, m, eventMessage(event, mention), event, 0)
+			return
+		}
+		publishEvent(ctx, s, m, event, mention)
+	} else {
+		s.ChannelMessageSend(m.ChannelID, "This command is unavailable")
+	}
+}
+
+// publishEvent sends the event to the public server and offers to tweet it
+func publishEvent(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, event *api.Event, mention string) {
+	channels := viper.Get("discord.channels").(*config.Channels)
+	s.ChannelMessageSendComplex(channels.PublicAnnouncements, eventMessage(event, mention))
+	prometheus.EventCreate()
+	offerTweet(ctx, s, m, event)
+}
+
+// publishEventWebsite confirms the event is on the website, which reads events straight from the committee channel
+func publishEventWebsite(s *discordgo.Session, m *discordgo.MessageCreate) {
+	s.ChannelMessageSend(m.ChannelID, "Event successfully posted to website! (Depending on cache may take a few minutes)")
+}
+
+// eventMessage is the message an event is published with, either as an embed or plain text
+// Events which would mention everyone only mention the notification roles of their tags, if they have any
+func eventMessage(event *api.Event, mention string) *discordgo.MessageSend {
+	if mention == "@everyone" {
+		mention = tagMention(event.Tags, mention)
+	}
+	if !viper.GetBool("events.embed") {
+		return &discordgo.MessageSend{
+			Content: fmt.Sprintf(
 				"Hey %s, we have another upcoming event on *%s*:\n**%s**\n%s",
 				mention,
 				event.Date.Format(layoutIE),
 				event.Title,
 				event.Description,
 			),
-			"poster.jpg",
-			io.TeeReader(event.ImgData, b),
-		)
-		prometheus.EventCreate()
-		event.ImgData = b
-		if len(event.Description) < viper.GetInt("discord.charlimit") {
-			s.MessageReactionAdd(m.ChannelID, m.ID, string(twitter))
-			reactionMap[m.ID] = event
+			Files: imageFiles(event.Images),
 		}
+	}
+	when := event.Date.Format("Monday, 2 January 2006")
+	if event.HasTime() {
+		when = event.Date.Format("Monday, 2 January 2006 at 15:04")
+	}
+	website := viper.GetString("events.website")
+	emb := embed.NewEmbed().
+		SetTitle(event.Title).
+		SetDescription(event.Description).
+		SetColor(tagColour(event.Tags)).
+		AddField("When", when).
+		SetFooter("See all our upcoming events at " + website).
+		TruncateTitle()
+	emb.URL = website
+	if event.Location != "" {
+		emb.AddField("Where", event.Location)
+	}
+	if len(event.Tags) > 0 {
+		emb.AddField("Tags", strings.Join(event.Tags, ", "))
+	}
+	// The poster is attached to the message and shown inside the embed
+	// Events read back from the channel may have had all their images skipped
+	if len(event.Images) > 0 {
+		emb.SetImage("attachment://" + event.Images[0].Filename)
+	}
+	return &discordgo.MessageSend{
+		Content: fmt.Sprintf("Hey %s, we have another upcoming event!", mention),
+		Embed:   emb.MessageEmbed,
+		Files:   imageFiles(event.Images),
+	}
+}
 
-	} else {
-		s.ChannelMessageSend(m.ChannelID, "This command is unavailable")
+// isEventMessage checks if a public message is the post for the event
+func isEventMessage(message *discordgo.Message, event *api.Event) bool {
+	if len(message.Embeds) > 0 {
+		return message.Embeds[0].Title == embed.NewEmbed().SetTitle(event.Title).TruncateTitle().Title &&
+			message.Embeds[0].Description == embed.NewEmbed().SetDescription(event.Description).Description
 	}
+	// The mention could be everyone or the roles of the event's tags as configured when it was posted,
+	// so only the rest of the message is compared
+	rest := strings.TrimPrefix(eventMessage(event, "").Content, "Hey ")
+	return strings.HasPrefix(message.Content, "Hey ") && strings.HasSuffix(message.Content, rest)
+}
+
+// tagColour returns the embed colour for the event's first tag with one, falling back to the default colour
+func tagColour(tags []string) int {
+	colours := configPairs("events.colours")
+	for _, tag := range tags {
+		if colour, ok := colours[tag]; ok {
+			return parseColour(colour)
+		}
+	}
+	return parseColour(colours["default"])
+}
+
+func parseColour(colour string) int {
+	value, err := strconv.ParseInt(strings.TrimPrefix(colour, "#"), 16, 32)
+	if err != nil {
+		return 0
+	}
+	return int(value)
+}
+
+// tagMention mentions the notification role of each of the event's tags
+// If none of the tags have a role, fallback is used instead
+func tagMention(tags []string, fallback string) string {
+	roles := configPairs("events.tag_roles")
+	mentions := []string{}
+	for _, tag := range tags {
+		if role, ok := roles[tag]; ok {
+			mentions = append(mentions, "<@&"+role+">")
+		}
+	}
+	if len(mentions) == 0 {
+		return fallback
+	}
+	return strings.Join(mentions, " ")
 }
 
 func addAnnouncement(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
@@ -89,28 +187,38 @@ func announcement(ctx context.Context, s *discordgo.Session, m *discordgo.Messag
 			s.ChannelMessageSend(m.ChannelID, "Error sending announcement: "+err.Error())
 			return
 		}
-
-		if announcement.ImgData != nil {
-			b := bytes.NewBuffer([]byte{})
-			s.ChannelFileSendWithMessage(
-				channels.PublicAnnouncements,
-				fmt.Sprintf("%s%s", mention, announcement.Content),
-				"poster.jpg",
-				io.TeeReader(announcement.ImgData, b),
-			)
-			announcement.ImgData = b
-		} else {
-			s.ChannelMessageSend(channels.PublicAnnouncements, fmt.Sprintf("%s%s", mention, announcement.Content))
-		}
-		if len(announcement.Content) < viper.GetInt("discord.charlimit") {
-			s.MessageReactionAdd(m.ChannelID, m.ID, string(twitter))
-			reactionMap[m.ID] = announcement
+		required := viper.GetInt("announce.approvals")
+		if required > 0 || previewRequested(m) {
+			createDraft(ctx, s, m, announcementMessage(announcement, mention), announcement, required)
+			return
 		}
+		publishAnnouncement(ctx, s, m, announcement, mention)
 	} else {
 		s.ChannelMessageSend(m.ChannelID, "This command is unavailable")
 	}
 }
 
+// publishAnnouncement sends the announcement to the public server and offers to tweet it
+func publishAnnouncement(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, announcement *api.Announcement, mention string) {
+	channels := viper.Get("discord.channels").(*config.Channels)
+	s.ChannelMessageSendComplex(channels.PublicAnnouncements, announcementMessage(announcement, mention))
+	offerTweet(ctx, s, m, announcement)
+}
+
+// announcementMessage is the message an announcement is published with
+func announcementMessage(announcement *api.Announcement, mention string) *discordgo.MessageSend {
+	return &discordgo.MessageSend{
+		Content: fmt.Sprintf("%s%s", mention, announcement.Content),
+		Files:   imageFiles(announcement.Images),
+	}
+}
+
+// previewRequested checks if the command was given the preview flag
+func previewRequested(m *discordgo.MessageCreate) bool {
+	fields := strings.Fields(m.Content)
+	return len(fields) > 1 && fields[1] == api.PreviewFlag
+}
+
 // recall events and announcements
 func recall(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
 	channels := viper.Get("discord.channels").(*config.Channels)
@@ -126,6 +234,7 @@ func recall(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreat
 		for _, message := range private {
 			if strings.HasPrefix(message.Content, viper.GetString("bot.prefix")+"announce"+" ") {
 				content := strings.TrimPrefix(message.Content, viper.GetString("bot.prefix")+"announce"+" ")
+				content = strings.TrimPrefix(content, api.PreviewFlag+" ")
 				s.ChannelMessageDelete(channels.PrivateEvents, message.ID)
 				for _, publicMessage := range public {
 					publicContent := strings.Trim(strings.Join(strings.Split(publicMessage.Content, "\n")[1:], "\n"), " ")
@@ -138,6 +247,7 @@ func recall(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreat
 
 			} else if strings.HasPrefix(message.Content, viper.GetString("bot.prefix")+"sannounce"+" ") {
 				content := strings.TrimPrefix(message.Content, viper.GetString("bot.prefix")+"sannounce"+" ")
+				content = strings.TrimPrefix(content, api.PreviewFlag+" ")
 				s.ChannelMessageDelete(channels.PrivateEvents, message.ID)
 				for _, publicMessage := range public {
 					publicContent := strings.Trim(publicMessage.Content, " ")
@@ -148,22 +258,15 @@ func recall(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreat
 					}
 				}
 			} else if strings.HasPrefix(message.Content, viper.GetString("bot.prefix")+"event"+" ") {
-				create := &discordgo.MessageCreate{Message: message}
-				event, err := api.ParseEvent(create, committeeHelpStrings["event"])
+				event, err := api.ReadEvent(message)
 				if err != nil {
 					log.WithContext(ctx).WithError(err).Error("failed to parse event")
 					continue
 				}
 				// Found event
 				s.ChannelMessageDelete(channels.PrivateEvents, message.ID)
-				content := fmt.Sprintf(
-					"Hey @everyone, we have a new upcoming event on *%s*:\n**%s**\n%s",
-					event.Date.Format(layoutIE),
-					event.Title,
-					event.Description,
-				)
 				for _, publicMessage := range public {
-					if content == publicMessage.Content {
+					if isEventMessage(publicMessage, event) {
 						s.ChannelMessageDelete(channels.PublicAnnouncements, publicMessage.ID)
 						s.ChannelMessageSend(m.ChannelID, "Successfully recalled event\n"+fmt.Sprintf("**%s**\n%s", event.Title, event.Description))
 						prometheus.EventRevoke()
@@ -174,3 +277,12 @@ func recall(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreat
 		}
 	}
 }
+
+// imageFiles for attaching images to a discord message, keeping their original filenames
+func imageFiles(imgs []*api.Image) []*discordgo.File {
+	files := []*discordgo.File{}
+	for _, img := range imgs {
+		files = append(files, img.File())
+	}
+	return files
+}
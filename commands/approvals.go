@@ -0,0 +1,224 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Strum355/log"
+	"github.com/UCCNetsoc/discord-bot/api"
+	"github.com/UCCNetsoc/discord-bot/config"
+	"github.com/UCCNetsoc/discord-bot/database"
+	"github.com/UCCNetsoc/discord-bot/embed"
+	"github.com/bwmarrin/discordgo"
+	"github.com/spf13/viper"
+)
+
+const (
+	approve Reaction = "✅"
+	reject  Reaction = "🚫"
+)
+
+// createDraft previews a post in the committee channel exactly as it will be published, along with its tweets
+// It's published once required committee members other than the author approve it, or if required is 0,
+// once any committee member confirms it
+func createDraft(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, public *discordgo.MessageSend, entry api.Entry, required int) {
+	// Show the post without pinging anyone
+	public.AllowedMentions = &discordgo.MessageAllowedMentions{}
+	if _, err := s.ChannelMessageSendComplex(m.ChannelID, public); err != nil {
+		log.WithContext(ctx).WithError(err).Error("Failed to send draft preview")
+		s.ChannelMessageSend(m.ChannelID, "Failed to create draft: "+err.Error())
+		return
+	}
+
+	expires := time.Now().Add(viper.GetDuration("announce.draft_expiry"))
+	emb := embed.NewEmbed().
+		SetTitle("Draft").
+		SetAuthor(m.Author.Username, m.Author.AvatarURL(""))
+	if required > 0 {
+		emb.AddField("Approvals", fmt.Sprintf("0/%d", required)).
+			SetFooter(fmt.Sprintf("React with %s to approve or %s to reject. Expires %s", approve, reject, expires.Format(time.RFC1123)))
+	} else {
+		emb.AddField("Status", "Awaiting confirmation").
+			SetFooter(fmt.Sprintf("React with %s to publish or %s to discard. Expires %s", approve, reject, expires.Format(time.RFC1123)))
+	}
+	tweetFields(emb, splitThread(tweetText(s, m.GuildID, entry.GetContent()), viper.GetInt("discord.charlimit")))
+	status, err := s.ChannelMessageSendEmbed(m.ChannelID, emb.MessageEmbed)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("Failed to send draft status")
+		s.ChannelMessageSend(m.ChannelID, "Failed to create draft: "+err.Error())
+		return
+	}
+	commandStr, _ := extractCommand(m.Content)
+	err = database.SaveDraft(&database.Draft{
+		PreviewID: status.ID,
+		ChannelID: m.ChannelID,
+		CommandID: m.ID,
+		AuthorID:  m.Author.ID,
+		Kind:      commandStr,
+		Required:  required,
+		Expires:   expires,
+	})
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("Failed to save draft")
+		s.ChannelMessageSend(m.ChannelID, "Failed to create draft: "+err.Error())
+		return
+	}
+	s.MessageReactionAdd(m.ChannelID, status.ID, string(approve))
+	s.MessageReactionAdd(m.ChannelID, status.ID, string(reject))
+}
+
+// draftReaction handles committee reacting to a draft preview
+// It returns false if the message isn't a draft preview
+func draftReaction(ctx context.Context, s *discordgo.Session, r *discordgo.MessageReaction, added bool) bool {
+	draft, err := database.GetDraft(r.MessageID)
+	if errors.Is(err, database.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("Failed to get draft")
+		return true
+	}
+	if draft.Status != database.DraftPending {
+		return true
+	}
+	if draft.Expired() {
+		expireDraft(ctx, s, draft)
+		return true
+	}
+
+	switch Reaction(r.Emoji.Name) {
+	case approve:
+		if draft.Required == 0 {
+			// Only needs confirming
+			if added {
+				publishDraft(ctx, s, draft)
+			}
+			return true
+		}
+		if r.UserID == draft.AuthorID {
+			return true
+		}
+		var approvals int
+		if added {
+			approvals, err = database.AddApproval(draft.PreviewID, r.UserID)
+		} else {
+			approvals, err = database.RemoveApproval(draft.PreviewID, r.UserID)
+		}
+		if err != nil {
+			log.WithContext(ctx).WithError(err).Error("Failed to update approvals")
+			return true
+		}
+		updatePreview(s, draft, fmt.Sprintf("%d/%d", approvals, draft.Required))
+		if approvals >= draft.Required {
+			publishDraft(ctx, s, draft)
+		}
+	case reject:
+		if !added {
+			return true
+		}
+		finished, err := database.FinishDraft(draft.PreviewID, database.DraftRejected)
+		if err != nil {
+			log.WithContext(ctx).WithError(err).Error("Failed to reject draft")
+			return true
+		}
+		if finished && draft.Required == 0 {
+			updatePreview(s, draft, fmt.Sprintf("Discarded by <@%s>", r.UserID))
+		} else if finished {
+			updatePreview(s, draft, fmt.Sprintf("Rejected by <@%s>", r.UserID))
+		}
+	}
+	return true
+}
+
+// publishDraft parses the original command again and publishes it
+func publishDraft(ctx context.Context, s *discordgo.Session, draft *database.Draft) {
+	finished, err := database.FinishDraft(draft.PreviewID, database.DraftPublished)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("Failed to publish draft")
+		return
+	}
+	if !finished {
+		// Someone else got there first
+		return
+	}
+	message, err := s.ChannelMessage(draft.ChannelID, draft.CommandID)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("Failed to get draft command")
+		s.ChannelMessageSend(draft.ChannelID, "Failed to publish draft, the original message couldn't be found")
+		return
+	}
+	message.GuildID = viper.Get("discord.servers").(*config.Servers).CommitteeServer
+	m := &discordgo.MessageCreate{Message: message}
+
+	switch draft.Kind {
+	case "announce", "sannounce":
+		announcement, err := api.ParseAnnouncement(m, committeeHelpStrings["announce"])
+		if err != nil {
+			log.WithContext(ctx).WithError(err).Error("Failed to parse draft announcement")
+			s.ChannelMessageSend(draft.ChannelID, "Failed to publish draft: "+err.Error())
+			return
+		}
+		mention := "@everyone\n"
+		if draft.Kind == "sannounce" {
+			mention = ""
+		}
+		publishAnnouncement(ctx, s, m, announcement, mention)
+	case "event", "sevent", "wevent":
+		event, err := api.ParseEvent(m, committeeHelpStrings["event"])
+		if err != nil {
+			log.WithContext(ctx).WithError(err).Error("Failed to parse draft event")
+			s.ChannelMessageSend(draft.ChannelID, "Failed to publish draft: "+err.Error())
+			return
+		}
+		switch draft.Kind {
+		case "event":
+			publishEvent(ctx, s, m, event, "@everyone")
+		case "sevent":
+			publishEvent(ctx, s, m, event, "everyone")
+		case "wevent":
+			publishEventWebsite(s, m)
+		}
+	}
+	updatePreview(s, draft, "Published")
+}
+
+// expireDraft marks the draft as expired so it can't be published
+func expireDraft(ctx context.Context, s *discordgo.Session, draft *database.Draft) {
+	finished, err := database.FinishDraft(draft.PreviewID, database.DraftExpired)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("Failed to expire draft")
+		return
+	}
+	if finished {
+		updatePreview(s, draft, "Expired before it was published")
+	}
+}
+
+// expireDrafts periodically expires drafts which weren't published in time
+func expireDrafts(s *discordgo.Session) {
+	for {
+		drafts, err := database.ExpiredDrafts(time.Now())
+		if err != nil {
+			log.WithError(err).Error("Failed to get expired drafts")
+		}
+		for _, draft := range drafts {
+			expireDraft(context.Background(), s, draft)
+		}
+		<-time.After(time.Minute)
+	}
+}
+
+// updatePreview replaces the status field of a draft's preview
+func updatePreview(s *discordgo.Session, draft *database.Draft, status string) {
+	preview, err := s.ChannelMessage(draft.ChannelID, draft.PreviewID)
+	if err != nil || len(preview.Embeds) == 0 {
+		return
+	}
+	emb := preview.Embeds[0]
+	if len(emb.Fields) > 0 {
+		emb.Fields[0].Value = status
+	}
+	s.ChannelMessageEditEmbed(draft.ChannelID, draft.PreviewID, emb)
+}
@@ -0,0 +1,147 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Strum355/log"
+	"github.com/UCCNetsoc/discord-bot/config"
+	"github.com/UCCNetsoc/discord-bot/embed"
+	"github.com/UCCNetsoc/discord-bot/prometheus"
+	"github.com/UCCNetsoc/discord-bot/ring"
+	"github.com/bwmarrin/discordgo"
+	"github.com/spf13/viper"
+)
+
+var (
+	// Recent messages of each channel on the public server, so edits and deletes can show what changed
+	messageCache     = make(map[string]*ring.Ring)
+	messageCacheLock sync.Mutex
+)
+
+// cacheMessage remembers a message on the public server
+func cacheMessage(m *discordgo.Message) {
+	if !isAudited(m.GuildID, m.ChannelID) {
+		return
+	}
+	messageCacheLock.Lock()
+	defer messageCacheLock.Unlock()
+	cache, ok := messageCache[m.ChannelID]
+	if !ok {
+		cache = &ring.Ring{}
+		messageCache[m.ChannelID] = cache
+	}
+	cache.Push([]*discordgo.Message{m})
+}
+
+// cachedMessage returns a message remembered by cacheMessage, or nil if it's no longer cached
+func cachedMessage(channelID, messageID string) *discordgo.Message {
+	messageCacheLock.Lock()
+	defer messageCacheLock.Unlock()
+	if cache, ok := messageCache[channelID]; ok {
+		return cache.Find(messageID)
+	}
+	return nil
+}
+
+// isAudited checks if messages in the channel should be posted to the audit log
+func isAudited(guildID, channelID string) bool {
+	servers := viper.Get("discord.servers").(*config.Servers)
+	channels := viper.Get("discord.channels").(*config.Channels)
+	if guildID != servers.PublicServer || channels.AuditLog == "" {
+		return false
+	}
+	for _, ignored := range strings.Split(viper.GetString("audit.ignore_channels"), ",") {
+		if ignored == channelID {
+			return false
+		}
+	}
+	return true
+}
+
+// Called whenever a message is edited, posting the before and after to the audit log
+func messageUpdate(s *discordgo.Session, m *discordgo.MessageUpdate) {
+	// Embeds being added to a message also count as an update, but have no author
+	if m.Author == nil || m.Author.Bot || !isAudited(m.GuildID, m.ChannelID) {
+		return
+	}
+	before := cachedMessage(m.ChannelID, m.ID)
+	if before != nil && before.Content == m.Content {
+		return
+	}
+	messageCacheLock.Lock()
+	if cache, ok := messageCache[m.ChannelID]; !ok || !cache.Replace(m.Message) {
+		messageCacheLock.Unlock()
+		cacheMessage(m.Message)
+	} else {
+		messageCacheLock.Unlock()
+	}
+
+	beforeContent := "*Not cached*"
+	if before != nil {
+		beforeContent = quoteContent(before.Content)
+	}
+	emb := embed.NewEmbed().
+		SetTitle("Message Edited").
+		SetColor(0x2196F3).
+		SetAuthor(m.Author.String(), m.Author.AvatarURL("")).
+		AddField("Channel", fmt.Sprintf("<#%s> ([jump](https://discord.com/channels/%s/%s/%s))", m.ChannelID, m.GuildID, m.ChannelID, m.ID)).
+		AddField("Before", beforeContent).
+		AddField("After", quoteContent(m.Content)).
+		SetFooter(fmt.Sprintf("User ID: %s", m.Author.ID))
+	postAuditLog(s, emb)
+}
+
+// Called whenever a message is deleted, posting what it contained to the audit log
+func messageDelete(s *discordgo.Session, m *discordgo.MessageDelete) {
+	// Check if its not a DM
+	if len(m.GuildID) == 0 {
+		return
+	}
+	prometheus.MessageDelete(m.GuildID, m.ChannelID)
+	if !isAudited(m.GuildID, m.ChannelID) {
+		return
+	}
+	// Messages which aren't cached were sent by bots, before the bot started or too long ago,
+	// and aren't worth logging without their content
+	deleted := cachedMessage(m.ChannelID, m.ID)
+	if deleted == nil || deleted.Author == nil || deleted.Author.Bot {
+		return
+	}
+	emb := embed.NewEmbed().
+		SetTitle("Message Deleted").
+		SetColor(0xF44336).
+		SetAuthor(deleted.Author.String(), deleted.Author.AvatarURL("")).
+		AddField("Channel", fmt.Sprintf("<#%s>", m.ChannelID)).
+		SetFooter(fmt.Sprintf("User ID: %s", deleted.Author.ID))
+	if deleted.Content != "" {
+		emb.AddField("Content", quoteContent(deleted.Content))
+	}
+	if len(deleted.Attachments) > 0 {
+		attachments := []string{}
+		for _, attachment := range deleted.Attachments {
+			attachments = append(attachments, fmt.Sprintf("[%s](%s)", attachment.Filename, attachment.ProxyURL))
+		}
+		emb.AddField("Attachments", strings.Join(attachments, "\n"))
+	}
+	postAuditLog(s, emb)
+}
+
+func postAuditLog(s *discordgo.Session, emb *embed.Embed) {
+	channels := viper.Get("discord.channels").(*config.Channels)
+	if _, err := s.ChannelMessageSendEmbed(channels.AuditLog, emb.MessageEmbed); err != nil {
+		log.WithError(err).Error("Failed to post to audit log")
+	}
+}
+
+// quoteContent formats message content for an embed field, which can't be empty
+func quoteContent(content string) string {
+	if content == "" {
+		return "*Empty*"
+	}
+	if runes := []rune(content); len(runes) > embed.EmbedLimitFieldValue-6 {
+		content = string(runes[:embed.EmbedLimitFieldValue-9]) + "..."
+	}
+	return "```" + strings.ReplaceAll(content, "```", "'''") + "```"
+}
@@ -0,0 +1,241 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Strum355/log"
+	"github.com/UCCNetsoc/discord-bot/config"
+	"github.com/UCCNetsoc/discord-bot/database"
+	"github.com/UCCNetsoc/discord-bot/embed"
+	"github.com/bwmarrin/discordgo"
+	"github.com/spf13/viper"
+)
+
+// Actions automod rules can take
+const (
+	automodDelete  = "delete"
+	automodWarn    = "warn"
+	automodTimeout = "timeout"
+)
+
+var (
+	inviteRegex = regexp.MustCompile(`(?i)(discord\.gg|discord(app)?\.com/invite)/[\w-]+`)
+
+	// Recent messages of each user, for spotting duplicates
+	recentMessages     = make(map[string][]recentMessage)
+	recentMessagesLock sync.Mutex
+
+	wordPatterns     []*regexp.Regexp
+	wordPatternsOnce sync.Once
+)
+
+type recentMessage struct {
+	content string
+	sent    time.Time
+}
+
+// automodRule checks a message, returning why it broke the rule or an empty string
+type automodRule func(m *discordgo.MessageCreate) string
+
+// Rules in the order they're checked, named as in the automod config
+var automodRules = []struct {
+	name string
+	rule automodRule
+}{
+	{"spam", spamRule},
+	{"invites", inviteRule},
+	{"mentions", mentionRule},
+	{"words", wordRule},
+}
+
+// automod checks messages on the public server against each enabled rule and takes its actions
+// It returns true if the message broke a rule
+func automod(s *discordgo.Session, m *discordgo.MessageCreate) bool {
+	servers := viper.Get("discord.servers").(*config.Servers)
+	if m.GuildID != servers.PublicServer || isAutomodExempt(m) {
+		return false
+	}
+	// Every message is remembered, so duplicates are counted even if an earlier one broke another rule
+	rememberMessage(m)
+	for _, r := range automodRules {
+		actions := viper.GetString("automod." + r.name + ".action")
+		if actions == "" {
+			continue
+		}
+		if reason := r.rule(m); reason != "" {
+			ctx := context.WithValue(context.Background(), log.Key, log.Fields{
+				"author_id":  m.Author.ID,
+				"channel_id": m.ChannelID,
+				"rule":       r.name,
+			})
+			log.WithContext(ctx).Info("automod rule broken")
+			enforce(ctx, s, m, r.name, reason, strings.Split(actions, ","))
+			return true
+		}
+	}
+	return false
+}
+
+// enforce takes the rule's actions against the message and its author
+func enforce(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, rule, reason string, actions []string) {
+	channels := viper.Get("discord.channels").(*config.Channels)
+	if channels.ModLog != "" {
+		s.ChannelMessageSendEmbed(channels.ModLog, embed.NewEmbed().
+			SetTitle("Automod | "+rule).
+			SetColor(actionColours[database.ActionWarn]).
+			AddField("User", fmt.Sprintf("<@%s> (%s)", m.Author.ID, m.Author.ID)).
+			AddField("Channel", fmt.Sprintf("<#%s>", m.ChannelID)).
+			AddField("Reason", reason).
+			AddField("Actions", strings.Join(actions, ", ")).
+			AddField("Message", "```"+strings.ReplaceAll(m.Content, "```", "")+"```").
+			MessageEmbed)
+	}
+	for _, action := range actions {
+		var err error
+		c := &database.ModCase{
+			UserID:      m.Author.ID,
+			ModeratorID: s.State.User.ID,
+			Reason:      "Automod: " + reason,
+		}
+		switch strings.TrimSpace(action) {
+		case automodDelete:
+			err = s.ChannelMessageDelete(m.ChannelID, m.ID)
+		case automodWarn:
+			c.Action = database.ActionWarn
+			err = takeAction(ctx, s, c)
+		case automodTimeout:
+			expires := time.Now().Add(viper.GetDuration("automod.timeout"))
+			c.Action = database.ActionMute
+			c.Expires = &expires
+			err = takeAction(ctx, s, c)
+		}
+		if err != nil {
+			log.WithContext(ctx).WithError(err).WithFields(log.Fields{"action": action}).Error("failed to take automod action")
+		}
+	}
+}
+
+// isAutomodExempt checks if the author has a role which automod ignores
+func isAutomodExempt(m *discordgo.MessageCreate) bool {
+	if m.Member == nil {
+		return false
+	}
+	for _, role := range strings.Split(viper.GetString("automod.exempt_roles"), ",") {
+		if role != "" && containsRole(m.Member.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// rememberMessage adds the message to its author's recent messages, dropping those which have fallen out of the spam window
+func rememberMessage(m *discordgo.MessageCreate) {
+	content := messageKey(m)
+	if content == "" {
+		return
+	}
+	recentMessagesLock.Lock()
+	defer recentMessagesLock.Unlock()
+	recentMessages[m.Author.ID] = append(recentWindow(recentMessages[m.Author.ID], time.Now()), recentMessage{content, time.Now()})
+}
+
+// pruneRecentMessages periodically forgets the messages of users who have stopped posting
+func pruneRecentMessages() {
+	for {
+		<-time.After(time.Minute)
+		now := time.Now()
+		recentMessagesLock.Lock()
+		for userID, messages := range recentMessages {
+			if recent := recentWindow(messages, now); len(recent) > 0 {
+				recentMessages[userID] = recent
+			} else {
+				delete(recentMessages, userID)
+			}
+		}
+		recentMessagesLock.Unlock()
+	}
+}
+
+// recentWindow returns the messages still within the spam window
+func recentWindow(messages []recentMessage, now time.Time) []recentMessage {
+	window := viper.GetDuration("automod.spam.window")
+	recent := []recentMessage{}
+	for _, message := range messages {
+		if now.Sub(message.sent) < window {
+			recent = append(recent, message)
+		}
+	}
+	return recent
+}
+
+// messageKey is what a message is compared by when looking for duplicates
+func messageKey(m *discordgo.MessageCreate) string {
+	return strings.ToLower(strings.TrimSpace(m.Content))
+}
+
+// spamRule catches the same message being sent too many times in a short window
+func spamRule(m *discordgo.MessageCreate) string {
+	content := messageKey(m)
+	if content == "" {
+		return ""
+	}
+	window := viper.GetDuration("automod.spam.window")
+
+	recentMessagesLock.Lock()
+	defer recentMessagesLock.Unlock()
+	duplicates := 0
+	for _, message := range recentWindow(recentMessages[m.Author.ID], time.Now()) {
+		if message.content == content {
+			duplicates++
+		}
+	}
+	if duplicates >= viper.GetInt("automod.spam.count") {
+		return fmt.Sprintf("Sent the same message %d times in %s", duplicates, window)
+	}
+	return ""
+}
+
+// inviteRule catches invite links to other discord servers
+func inviteRule(m *discordgo.MessageCreate) string {
+	if invite := inviteRegex.FindString(m.Content); invite != "" {
+		return "Posted an invite link: " + invite
+	}
+	return ""
+}
+
+// mentionRule catches messages mentioning too many users or roles at once
+func mentionRule(m *discordgo.MessageCreate) string {
+	mentions := len(m.Mentions) + len(m.MentionRoles)
+	if m.MentionEveryone {
+		return "Mentioned everyone"
+	}
+	if mentions >= viper.GetInt("automod.mentions.max") {
+		return fmt.Sprintf("Mentioned %d users and roles", mentions)
+	}
+	return ""
+}
+
+// wordRule catches messages matching any of the configured patterns
+func wordRule(m *discordgo.MessageCreate) string {
+	wordPatternsOnce.Do(func() {
+		for _, pattern := range viper.GetStringSlice("automod.words.patterns") {
+			compiled, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				log.WithError(err).WithFields(log.Fields{"pattern": pattern}).Error("Invalid automod word pattern")
+				continue
+			}
+			wordPatterns = append(wordPatterns, compiled)
+		}
+	})
+	for _, pattern := range wordPatterns {
+		if pattern.MatchString(m.Content) {
+			return "Used a blocked word"
+		}
+	}
+	return ""
+}
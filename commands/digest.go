@@ -0,0 +1,158 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Strum355/log"
+	"github.com/UCCNetsoc/discord-bot/analytics"
+	"github.com/UCCNetsoc/discord-bot/api"
+	"github.com/UCCNetsoc/discord-bot/config"
+	"github.com/UCCNetsoc/discord-bot/database"
+	"github.com/UCCNetsoc/discord-bot/embed"
+	"github.com/bwmarrin/discordgo"
+	"github.com/spf13/viper"
+)
+
+// postDigests posts the weekly digest to the committee digest channel at the configured day and time
+func postDigests(s *discordgo.Session) {
+	for {
+		next, err := nextDigest(time.Now())
+		if err != nil {
+			log.WithError(err).Error("Invalid digest schedule, the weekly digest won't be posted")
+			return
+		}
+		<-time.After(time.Until(next))
+		channelID := viper.Get("discord.channels").(*config.Channels).Digest
+		if channelID == "" {
+			continue
+		}
+		emb, err := digestEmbed(s)
+		if err != nil {
+			log.WithError(err).Error("Failed to build weekly digest")
+			continue
+		}
+		if _, err := s.ChannelMessageSendEmbed(channelID, emb.MessageEmbed); err != nil {
+			log.WithError(err).Error("Failed to post weekly digest")
+		}
+	}
+}
+
+// nextDigest returns when the digest is next due after now
+func nextDigest(now time.Time) (time.Time, error) {
+	loc := config.Location()
+	at, err := time.Parse("15:04", viper.GetString("digest.time"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("digest.time must be given as HH:MM: %w", err)
+	}
+	day := -1
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.EqualFold(weekday.String(), viper.GetString("digest.day")) {
+			day = int(weekday)
+		}
+	}
+	if day < 0 {
+		return time.Time{}, errors.New("digest.day must be a day of the week")
+	}
+
+	now = now.In(loc)
+	next := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, loc)
+	next = next.AddDate(0, 0, (day-int(next.Weekday())+7)%7)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 7)
+	}
+	return next, nil
+}
+
+// digest posts the weekly digest in the channel it was asked for in, without waiting for it to be due
+func digest(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
+	if !isCommittee(s, m) {
+		return
+	}
+	emb, err := digestEmbed(s)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("failed to build weekly digest")
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Failed to build the digest: "+err.Error()))
+		return
+	}
+	s.ChannelMessageSendEmbed(m.ChannelID, emb.MessageEmbed)
+}
+
+// digestEmbed summarises the past week of the public server, the upcoming events and the uptime of the sites
+func digestEmbed(s *discordgo.Session) (*embed.Embed, error) {
+	servers := viper.Get("discord.servers").(*config.Servers)
+	guild, err := s.State.Guild(servers.PublicServer)
+	if err != nil {
+		return nil, err
+	}
+	report, err := analytics.Build(guild.MemberCount, 7*24*time.Hour, 10)
+	if err != nil {
+		return nil, err
+	}
+
+	emb := embed.NewEmbed().
+		SetTitle("Weekly Digest").
+		SetDescription(fmt.Sprintf("%s to %s, compared to the week before", report.From.Format(layoutIE), report.To.Format(layoutIE))).
+		SetColor(0x2196F3)
+	emb.AddField("Members", fmt.Sprintf("%d (%+d)", guild.MemberCount, guild.MemberCount-report.StartMembers)).
+		AddField("Joins", trend(report.Totals.Joins, report.Previous.Joins)).
+		AddField("Registrations", trend(report.Totals.Registrations, report.Previous.Registrations)).
+		AddField("Leaves", trend(report.Totals.Leaves, report.Previous.Leaves)).
+		AddField("Failed Registrations", trend(report.Totals.FailedRegistrations, report.Previous.FailedRegistrations))
+
+	channels := []string{}
+	total := 0
+	for _, channel := range report.Channels {
+		channels = append(channels, fmt.Sprintf("<#%s>: %d", channel.Channel, channel.Messages))
+		total += channel.Messages
+	}
+	if len(channels) > 0 {
+		emb.AddField(fmt.Sprintf("Messages (%d in the busiest channels)", total), strings.Join(channels, "\n"))
+	}
+
+	emb.AddField("Upcoming Events", upcomingEvents(5))
+
+	uptimes, err := database.SiteUptimes(report.From, report.To)
+	if err != nil {
+		log.WithError(err).Error("Failed to get site uptimes for digest")
+	}
+	sites := []string{}
+	for _, uptime := range uptimes {
+		status := "🆗"
+		if uptime.Up < uptime.Checks {
+			status = "🔥"
+		}
+		sites = append(sites, fmt.Sprintf("%s %s: %.2f%%", status, uptime.Site, float64(uptime.Up)*100/float64(uptime.Checks)))
+	}
+	if len(sites) > 0 {
+		emb.AddField("Site Uptime", strings.Join(sites, "\n"))
+	}
+	return emb, nil
+}
+
+// upcomingEvents lists the next few published events
+func upcomingEvents(limit int) string {
+	events, err := api.UpcomingEvents("")
+	if err != nil {
+		log.WithError(err).Error("Failed to get upcoming events for digest")
+		return "Failed to get the upcoming events"
+	}
+	if len(events) == 0 {
+		return "No events coming up"
+	}
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	lines := []string{}
+	for _, event := range events {
+		when := event.Date.Format("Mon 2 Jan")
+		if event.HasTime() {
+			when = event.Date.Format("Mon 2 Jan 15:04")
+		}
+		lines = append(lines, fmt.Sprintf("**%s**: %s", when, event.Title))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,209 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Strum355/log"
+	"github.com/UCCNetsoc/discord-bot/config"
+	"github.com/UCCNetsoc/discord-bot/database"
+	"github.com/UCCNetsoc/discord-bot/embed"
+	"github.com/UCCNetsoc/discord-bot/voice"
+	"github.com/bwmarrin/discordgo"
+	"github.com/spf13/viper"
+)
+
+var (
+	// Snapshot of each public server member, as the state has already been updated by the time
+	// member update and leave handlers are called
+	knownMembers     = make(map[string]*discordgo.Member)
+	knownMembersLock sync.Mutex
+)
+
+// rememberMember stores a copy of the member so later changes can be compared against it
+func rememberMember(member *discordgo.Member) {
+	if member.User == nil {
+		return
+	}
+	snapshot := *member
+	snapshot.Roles = append([]string{}, member.Roles...)
+	knownMembersLock.Lock()
+	knownMembers[member.User.ID] = &snapshot
+	knownMembersLock.Unlock()
+}
+
+// forgetMember removes the member's snapshot, returning it if there was one
+func forgetMember(userID string) *discordgo.Member {
+	knownMembersLock.Lock()
+	defer knownMembersLock.Unlock()
+	member := knownMembers[userID]
+	delete(knownMembers, userID)
+	return member
+}
+
+// Called when the bot connects to a server, remembering the members of the public server and who is in voice
+func guildCreate(s *discordgo.Session, g *discordgo.GuildCreate) {
+	if g.ID != viper.Get("discord.servers").(*config.Servers).PublicServer {
+		return
+	}
+	for _, member := range g.Members {
+		rememberMember(member)
+	}
+	voice.Start(s, g.ID, g.VoiceStates)
+}
+
+// logMemberJoin posts a new member to the member log, warning if their account is new
+func logMemberJoin(s *discordgo.Session, m *discordgo.GuildMemberAdd) {
+	rememberMember(m.Member)
+	if err := database.RecordMemberEvent(m.User.ID, database.MemberJoined); err != nil {
+		log.WithError(err).Error("Failed to record member join")
+	}
+	emb := memberEmbed("Member Joined", 0x4CAF50, m.Member)
+	created, err := discordgo.SnowflakeTimestamp(m.User.ID)
+	if err == nil {
+		emb.AddField("Account Created", fmt.Sprintf("%s (%s ago)", created.Format(layoutIE), formatAge(time.Since(created))))
+		if time.Since(created) < viper.GetDuration("members.new_account_age") {
+			emb.SetColor(0xFF9800).AddField("Warning", "This account was created recently")
+		}
+	}
+	postMemberLog(s, emb)
+}
+
+// Called whenever a member leaves the server
+func memberLeave(s *discordgo.Session, m *discordgo.GuildMemberRemove) {
+	if m.GuildID != viper.Get("discord.servers").(*config.Servers).PublicServer {
+		return
+	}
+	if err := database.RecordMemberEvent(m.User.ID, database.MemberLeft); err != nil {
+		log.WithError(err).Error("Failed to record member leave")
+	}
+	emb := memberEmbed("Member Left", 0xF44336, m.Member)
+	member := forgetMember(m.User.ID)
+	if member == nil {
+		emb.AddField("Registered", "Unknown")
+		postMemberLog(s, emb)
+		return
+	}
+	if joined, err := member.JoinedAt.Parse(); err == nil {
+		emb.AddField("Joined", fmt.Sprintf("%s (%s ago)", joined.Format(layoutIE), formatAge(time.Since(joined))))
+	}
+	registered := "No"
+	if isRegistered(member.Roles) {
+		registered = "Yes"
+	}
+	emb.AddField("Registered", registered)
+	if len(member.Roles) > 0 {
+		emb.AddField("Roles", roleMentions(member.Roles))
+	}
+	postMemberLog(s, emb)
+}
+
+// Called whenever a member's nickname or roles change
+func memberUpdate(s *discordgo.Session, m *discordgo.GuildMemberUpdate) {
+	if m.GuildID != viper.Get("discord.servers").(*config.Servers).PublicServer || m.User == nil {
+		return
+	}
+	knownMembersLock.Lock()
+	before := knownMembers[m.User.ID]
+	knownMembersLock.Unlock()
+	rememberMember(m.Member)
+	// Large servers only send some of their members on connecting, so there may be nothing to compare against
+	if before == nil {
+		emb := memberEmbed("Member Updated", 0x2196F3, m.Member).
+			AddField("Nickname", nickname(m.Nick)).
+			AddField("Roles", roleMentions(m.Roles)).
+			SetFooter(fmt.Sprintf("User ID: %s | Their previous nickname and roles aren't known", m.User.ID))
+		postMemberLog(s, emb)
+		return
+	}
+	if before.Nick != m.Nick {
+		emb := memberEmbed("Nickname Changed", 0x2196F3, m.Member).
+			AddField("Before", nickname(before.Nick)).
+			AddField("After", nickname(m.Nick))
+		postMemberLog(s, emb)
+	}
+	added := roleDifference(m.Roles, before.Roles)
+	removed := roleDifference(before.Roles, m.Roles)
+	if len(added) > 0 || len(removed) > 0 {
+		emb := memberEmbed("Roles Changed", 0x9C27B0, m.Member)
+		if len(added) > 0 {
+			emb.AddField("Added", roleMentions(added))
+		}
+		if len(removed) > 0 {
+			emb.AddField("Removed", roleMentions(removed))
+		}
+		postMemberLog(s, emb)
+	}
+}
+
+func memberEmbed(title string, colour int, member *discordgo.Member) *embed.Embed {
+	return embed.NewEmbed().
+		SetTitle(title).
+		SetColor(colour).
+		SetAuthor(member.User.String(), member.User.AvatarURL("")).
+		SetDescription(member.User.Mention()).
+		SetFooter(fmt.Sprintf("User ID: %s", member.User.ID))
+}
+
+func postMemberLog(s *discordgo.Session, emb *embed.Embed) {
+	channels := viper.Get("discord.channels").(*config.Channels)
+	if channels.MemberLog == "" {
+		return
+	}
+	if _, err := s.ChannelMessageSendEmbed(channels.MemberLog, emb.MessageEmbed); err != nil {
+		log.WithError(err).Error("Failed to post to member log")
+	}
+}
+
+// isRegistered checks if the roles include one given on completing registration
+func isRegistered(roles []string) bool {
+	for _, roleID := range strings.Split(viper.GetString("discord.roles"), ",") {
+		if roleID != "" && containsRole(roles, roleID) {
+			return true
+		}
+	}
+	return false
+}
+
+// roleDifference returns the roles in a which aren't in b
+func roleDifference(a, b []string) []string {
+	difference := []string{}
+	for _, role := range a {
+		if !containsRole(b, role) {
+			difference = append(difference, role)
+		}
+	}
+	return difference
+}
+
+func roleMentions(roles []string) string {
+	if len(roles) == 0 {
+		return "*None*"
+	}
+	mentions := []string{}
+	for _, role := range roles {
+		mentions = append(mentions, "<@&"+role+">")
+	}
+	return strings.Join(mentions, " ")
+}
+
+func nickname(nick string) string {
+	if nick == "" {
+		return "*None*"
+	}
+	return nick
+}
+
+// formatAge describes a duration in the largest whole unit of days, hours or minutes
+func formatAge(age time.Duration) string {
+	switch {
+	case age >= 48*time.Hour:
+		return fmt.Sprintf("%d days", int(age.Hours()/24))
+	case age >= 2*time.Hour:
+		return fmt.Sprintf("%d hours", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%d minutes", int(age.Minutes()))
+	}
+}
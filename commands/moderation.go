@@ -0,0 +1,297 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Strum355/log"
+	"github.com/UCCNetsoc/discord-bot/config"
+	"github.com/UCCNetsoc/discord-bot/database"
+	"github.com/UCCNetsoc/discord-bot/embed"
+	"github.com/bwmarrin/discordgo"
+	"github.com/spf13/viper"
+)
+
+var (
+	userIDRegex   = regexp.MustCompile(`^<?@?!?(\d+)>?$`)
+	durationRegex = regexp.MustCompile(`^(\d+)([smhdw])$`)
+	durationUnits = map[string]time.Duration{
+		"s": time.Second,
+		"m": time.Minute,
+		"h": time.Hour,
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+	actionColours = map[string]int{
+		database.ActionWarn:   0xFFC107,
+		database.ActionMute:   0xFF9800,
+		database.ActionUnmute: 0x4CAF50,
+		database.ActionKick:   0xFF5722,
+		database.ActionBan:    0xF44336,
+		database.ActionUnban:  0x4CAF50,
+	}
+)
+
+func warn(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
+	moderate(ctx, s, m, database.ActionWarn, false)
+}
+
+func mute(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
+	moderate(ctx, s, m, database.ActionMute, true)
+}
+
+func unmute(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
+	moderate(ctx, s, m, database.ActionUnmute, false)
+}
+
+func kick(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
+	moderate(ctx, s, m, database.ActionKick, false)
+}
+
+func ban(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
+	moderate(ctx, s, m, database.ActionBan, true)
+}
+
+func unban(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
+	moderate(ctx, s, m, database.ActionUnban, false)
+}
+
+// moderate parses a moderation command in the format *`!action @user [duration] reason`* and takes the action
+func moderate(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, action string, allowDuration bool) {
+	if !isCommittee(s, m) {
+		return
+	}
+	_, body := extractCommand(m.Content)
+	args := strings.Fields(body)[1:]
+	if len(args) < 1 || !userIDRegex.MatchString(args[0]) {
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Please mention the user or give their id\n"+committeeHelpStrings[action]))
+		return
+	}
+	c := &database.ModCase{
+		UserID:      userIDRegex.FindStringSubmatch(args[0])[1],
+		ModeratorID: m.Author.ID,
+		Action:      action,
+	}
+	args = args[1:]
+	if allowDuration && len(args) > 0 {
+		if duration, ok := parseDuration(args[0]); ok {
+			expires := time.Now().Add(duration)
+			c.Expires = &expires
+			args = args[1:]
+		}
+	}
+	c.Reason = strings.Join(args, " ")
+	if c.Reason == "" {
+		c.Reason = "No reason given"
+	}
+
+	if err := takeAction(ctx, s, c); err != nil {
+		log.WithContext(ctx).WithError(err).WithFields(log.Fields{"action": action, "user_id": c.UserID}).Error("failed to take moderation action")
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed(fmt.Sprintf("Failed to %s <@%s>: %s", action, c.UserID, err.Error())))
+		return
+	}
+	s.ChannelMessageSendEmbed(m.ChannelID, caseEmbed(c).MessageEmbed)
+}
+
+// takeAction applies a case to the member in the public server, then records it and posts it to the mod log
+func takeAction(ctx context.Context, s *discordgo.Session, c *database.ModCase) error {
+	servers := viper.Get("discord.servers").(*config.Servers)
+	muteRole := viper.GetString("moderation.mute_role")
+	if (c.Action == database.ActionMute || c.Action == database.ActionUnmute) && muteRole == "" {
+		return errors.New("moderation.mute_role isn't set, so members can't be muted")
+	}
+	// Members who are kicked or banned are messaged first, they can't be reached once they've been removed from the server.
+	// Everyone else is only messaged once the action has been taken
+	removed := c.Action == database.ActionKick || c.Action == database.ActionBan
+	dmChannel := ""
+	if removed {
+		dmChannel = notifyModerated(ctx, s, c)
+	}
+
+	var err error
+	switch c.Action {
+	// Earlier mutes and bans are only resolved once the new one has been applied, so they aren't lost if it fails
+	case database.ActionMute:
+		if err = s.GuildMemberRoleAdd(servers.PublicServer, c.UserID, muteRole); err == nil {
+			err = database.ResolveCases(c.UserID, database.ActionMute)
+		}
+	// Members who have left can't be unmuted and users unbanned by hand can't be unbanned,
+	// but either way there's nothing left to lift
+	case database.ActionUnmute:
+		if err = s.GuildMemberRoleRemove(servers.PublicServer, c.UserID, muteRole); err == nil || notFound(err) {
+			err = database.ResolveCases(c.UserID, database.ActionMute)
+		}
+	case database.ActionKick:
+		err = s.GuildMemberDeleteWithReason(servers.PublicServer, c.UserID, c.Reason)
+	case database.ActionBan:
+		if err = s.GuildBanCreateWithReason(servers.PublicServer, c.UserID, c.Reason, 0); err == nil {
+			err = database.ResolveCases(c.UserID, database.ActionBan)
+		}
+	case database.ActionUnban:
+		if err = s.GuildBanDelete(servers.PublicServer, c.UserID); err == nil || notFound(err) {
+			err = database.ResolveCases(c.UserID, database.ActionBan)
+		}
+	}
+	if err != nil {
+		if dmChannel != "" {
+			s.ChannelMessageSend(dmChannel, fmt.Sprintf("Sorry, the %s above failed and wasn't applied after all.", c.Action))
+		}
+		return err
+	}
+	if !removed {
+		notifyModerated(ctx, s, c)
+	}
+	if c.Action == database.ActionUnmute || c.Action == database.ActionUnban {
+		c.Resolved = true
+	}
+	if err := database.CreateCase(c); err != nil {
+		return fmt.Errorf("action was taken but the case couldn't be recorded: %w", err)
+	}
+	logCase(ctx, s, c)
+	return nil
+}
+
+// notFound checks if a Discord API request failed as what it acted on doesn't exist
+func notFound(err error) bool {
+	var restErr *discordgo.RESTError
+	return errors.As(err, &restErr) && restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound
+}
+
+// notifyModerated lets the member know about the action taken against them, returning the DM channel it was sent in
+// Nothing is sent for actions which lift earlier ones, and the channel is empty then or if the message couldn't be sent
+func notifyModerated(ctx context.Context, s *discordgo.Session, c *database.ModCase) string {
+	var message string
+	switch c.Action {
+	case database.ActionWarn:
+		message = "You have been warned in the Netsoc Discord Server"
+	case database.ActionMute:
+		message = "You have been muted in the Netsoc Discord Server"
+	case database.ActionKick:
+		message = "You have been kicked from the Netsoc Discord Server"
+	case database.ActionBan:
+		message = "You have been banned from the Netsoc Discord Server"
+	default:
+		return ""
+	}
+	if c.Expires != nil {
+		message += " until " + c.Expires.Format(time.RFC1123)
+	}
+	channel, err := s.UserChannelCreate(c.UserID)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Warn("failed to create DM channel for moderated user")
+		return ""
+	}
+	_, err = s.ChannelMessageSendEmbed(channel.ID, embed.NewEmbed().
+		SetTitle(message).
+		AddField("Reason", c.Reason).
+		SetColor(actionColours[c.Action]).
+		MessageEmbed)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Warn("failed to message moderated user")
+		return ""
+	}
+	return channel.ID
+}
+
+// logCase posts the case to the committee mod log channel
+func logCase(ctx context.Context, s *discordgo.Session, c *database.ModCase) {
+	channels := viper.Get("discord.channels").(*config.Channels)
+	if channels.ModLog == "" {
+		return
+	}
+	if _, err := s.ChannelMessageSendEmbed(channels.ModLog, caseEmbed(c).MessageEmbed); err != nil {
+		log.WithContext(ctx).WithError(err).Error("failed to post to mod log")
+	}
+}
+
+func caseEmbed(c *database.ModCase) *embed.Embed {
+	emb := embed.NewEmbed().
+		SetTitle(fmt.Sprintf("Case #%d | %s", c.ID, strings.Title(c.Action))).
+		SetColor(actionColours[c.Action]).
+		AddField("User", fmt.Sprintf("<@%s> (%s)", c.UserID, c.UserID)).
+		AddField("Moderator", fmt.Sprintf("<@%s>", c.ModeratorID)).
+		AddField("Reason", c.Reason)
+	if c.Expires != nil {
+		emb.AddField("Expires", c.Expires.Format(time.RFC1123))
+	}
+	return emb
+}
+
+// cases lists the moderation history of a member
+func cases(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
+	if !isCommittee(s, m) {
+		return
+	}
+	args := strings.Fields(m.Content)
+	if len(args) < 2 || !userIDRegex.MatchString(args[1]) {
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Please mention the user or give their id"))
+		return
+	}
+	userID := userIDRegex.FindStringSubmatch(args[1])[1]
+	history, err := database.UserCases(userID)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("failed to get cases")
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Failed to get cases"))
+		return
+	}
+	emb := embed.NewEmbed().SetTitle(fmt.Sprintf("Cases for %s", userID))
+	if len(history) == 0 {
+		emb.SetDescription(fmt.Sprintf("<@%s> has a clean record", userID))
+	}
+	for _, c := range history {
+		if len(emb.Fields) == embed.EmbedLimitField {
+			break
+		}
+		name := fmt.Sprintf("#%d %s", c.ID, c.Action)
+		if c.Expires != nil && !c.Resolved {
+			name += " until " + c.Expires.Format(layoutIE)
+		}
+		emb.AddField(name, fmt.Sprintf("%s\nby <@%s> on %s", c.Reason, c.ModeratorID, c.Created.Format(layoutIE)))
+	}
+	s.ChannelMessageSendEmbed(m.ChannelID, emb.MessageEmbed)
+}
+
+// expireCases periodically lifts mutes and bans which have expired
+func expireCases(s *discordgo.Session) {
+	for {
+		expired, err := database.ExpiredCases(time.Now())
+		if err != nil {
+			log.WithError(err).Error("Failed to get expired cases")
+		}
+		for _, c := range expired {
+			lift := &database.ModCase{
+				UserID:      c.UserID,
+				ModeratorID: s.State.User.ID,
+				Action:      database.ActionUnmute,
+				Reason:      fmt.Sprintf("Case #%d expired", c.ID),
+			}
+			if c.Action == database.ActionBan {
+				lift.Action = database.ActionUnban
+			}
+			ctx := context.WithValue(context.Background(), log.Key, log.Fields{"case": c.ID, "user_id": c.UserID})
+			if err := takeAction(ctx, s, lift); err != nil {
+				log.WithContext(ctx).WithError(err).Error("Failed to lift expired case")
+			}
+		}
+		<-time.After(time.Minute)
+	}
+}
+
+// parseDuration parses durations such as 30m, 12h, 7d or 2w
+func parseDuration(duration string) (time.Duration, bool) {
+	matches := durationRegex.FindStringSubmatch(duration)
+	if matches == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(n) * durationUnits[matches[2]], true
+}
@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Strum355/log"
+	"github.com/UCCNetsoc/discord-bot/config"
+	"github.com/UCCNetsoc/discord-bot/embed"
+	"github.com/bwmarrin/discordgo"
+	"github.com/spf13/viper"
+)
+
+// notify toggles the notification role for an event tag, so members are only pinged for events they care about
+func notify(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
+	args := strings.Fields(strings.TrimPrefix(strings.TrimSpace(m.Content), viper.GetString("bot.prefix")+"notify"))
+	roles := configPairs("events.tag_roles")
+	if len(args) < 1 {
+		tags := []string{}
+		for tag := range roles {
+			tags = append(tags, "`"+tag+"`")
+		}
+		sort.Strings(tags)
+		if len(tags) == 0 {
+			s.ChannelMessageSend(m.ChannelID, "There are no event notifications to sign up for at the moment")
+			return
+		}
+		s.ChannelMessageSendEmbed(m.ChannelID, embed.NewEmbed().
+			SetTitle("Event Notifications").
+			SetDescription("Use *`!notify TAG`* to be notified about events with that tag, and again to stop.\n\n"+strings.Join(tags, ", ")).
+			MessageEmbed)
+		return
+	}
+
+	tag := strings.ToLower(args[0])
+	roleID, ok := roles[tag]
+	if !ok {
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed(fmt.Sprintf("There are no notifications for `%s`, use *`!notify`* to see them all", tag)))
+		return
+	}
+
+	servers := viper.Get("discord.servers").(*config.Servers)
+	member, err := s.GuildMember(servers.PublicServer, m.Author.ID)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("failed to get member")
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("You need to be in the Netsoc Discord Server to get notifications"))
+		return
+	}
+	if containsRole(member.Roles, roleID) {
+		err = s.GuildMemberRoleRemove(servers.PublicServer, m.Author.ID, roleID)
+	} else {
+		err = s.GuildMemberRoleAdd(servers.PublicServer, m.Author.ID, roleID)
+	}
+	if err != nil {
+		log.WithContext(ctx).
+			WithError(err).
+			WithFields(log.Fields{"role_id": roleID, "target_guild_id": servers.PublicServer}).
+			Error("failed to toggle notification role")
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Failed to update your notifications, please contact a SysAdmin :("))
+		return
+	}
+	if containsRole(member.Roles, roleID) {
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("You'll no longer be notified about %s events", tag))
+	} else {
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("You'll now be notified about %s events", tag))
+	}
+}
@@ -0,0 +1,203 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Strum355/log"
+	"github.com/UCCNetsoc/discord-bot/config"
+	"github.com/UCCNetsoc/discord-bot/embed"
+	"github.com/bwmarrin/discordgo"
+	"github.com/spf13/viper"
+)
+
+// raid tracks the join rate of the public server and whether raid mode is on
+var raid struct {
+	sync.Mutex
+	active bool
+	// Enabled by a command, so it stays on until it's turned off by one
+	manual     bool
+	since      time.Time
+	joins      []time.Time
+	suppressed int
+	// Verification level of the server before raid mode raised it
+	previousLevel *discordgo.VerificationLevel
+}
+
+// recordJoin counts a join towards the join rate, enabling raid mode if it's too high
+// It returns whether raid mode is on, in which case the member shouldn't be welcomed
+func recordJoin(s *discordgo.Session) bool {
+	raid.Lock()
+	now := time.Now()
+	window := now.Add(-viper.GetDuration("raid.window"))
+	joins := raid.joins[:0]
+	for _, joined := range append(raid.joins, now) {
+		if joined.After(window) {
+			joins = append(joins, joined)
+		}
+	}
+	raid.joins = joins
+	if raid.active {
+		raid.suppressed++
+		raid.Unlock()
+		return true
+	}
+	triggered := len(raid.joins) >= viper.GetInt("raid.joins")
+	raid.Unlock()
+	if triggered {
+		enableRaidMode(s, false, fmt.Sprintf("%d members joined in the last %s", len(joins), viper.GetDuration("raid.window")))
+	}
+	return triggered
+}
+
+// enableRaidMode stops welcoming new members, raises the verification level if configured and alerts committee
+// Discord is only called once the state has been updated, as joins wait on the lock
+func enableRaidMode(s *discordgo.Session, manual bool, reason string) bool {
+	raid.Lock()
+	if raid.active {
+		raid.manual = raid.manual || manual
+		raid.Unlock()
+		return false
+	}
+	since := time.Now()
+	raid.active = true
+	raid.manual = manual
+	raid.since = since
+	raid.suppressed = 0
+	raid.Unlock()
+
+	emb := embed.NewEmbed().
+		SetTitle("Raid Mode Enabled").
+		SetColor(0xF44336).
+		SetDescription("New members won't be welcomed or sent registration messages until raid mode is turned off with *`!raid off`*").
+		AddField("Reason", reason)
+	servers := viper.Get("discord.servers").(*config.Servers)
+	if level := discordgo.VerificationLevel(viper.GetInt("raid.verification_level")); level > 0 {
+		guild, err := s.Guild(servers.PublicServer)
+		if err != nil {
+			log.WithError(err).Error("Failed to get Public Server guild")
+		} else if guild.VerificationLevel < level {
+			if _, err := s.GuildEdit(servers.PublicServer, discordgo.GuildParams{VerificationLevel: &level}); err != nil {
+				log.WithError(err).Error("Failed to raise verification level")
+				emb.AddField("Verification Level", "Failed to raise: "+err.Error())
+			} else {
+				previous := guild.VerificationLevel
+				raid.Lock()
+				// Raid mode may have been turned off while the level was being raised, in which case it's put back now
+				current := raid.active && raid.since.Equal(since)
+				if current {
+					raid.previousLevel = &previous
+				}
+				raid.Unlock()
+				if current {
+					emb.AddField("Verification Level", fmt.Sprintf("Raised from %d to %d", previous, level))
+				} else if _, err := s.GuildEdit(servers.PublicServer, discordgo.GuildParams{VerificationLevel: &previous}); err != nil {
+					log.WithError(err).Error("Failed to restore verification level")
+				}
+			}
+		}
+	}
+	log.WithFields(log.Fields{"reason": reason, "manual": manual}).Warn("Raid mode enabled")
+	alertCommittee(s, emb)
+	return true
+}
+
+// disableRaidMode welcomes new members again and restores the verification level
+func disableRaidMode(s *discordgo.Session, reason string) bool {
+	raid.Lock()
+	if !raid.active {
+		raid.Unlock()
+		return false
+	}
+	since, suppressed, previousLevel := raid.since, raid.suppressed, raid.previousLevel
+	raid.active = false
+	raid.manual = false
+	raid.previousLevel = nil
+	raid.joins = nil
+	raid.Unlock()
+
+	emb := embed.NewEmbed().
+		SetTitle("Raid Mode Disabled").
+		SetColor(0x4CAF50).
+		AddField("Reason", reason).
+		AddField("Duration", time.Since(since).Round(time.Second).String()).
+		AddField("Members Not Welcomed", fmt.Sprint(suppressed))
+	if previousLevel != nil {
+		servers := viper.Get("discord.servers").(*config.Servers)
+		if _, err := s.GuildEdit(servers.PublicServer, discordgo.GuildParams{VerificationLevel: previousLevel}); err != nil {
+			log.WithError(err).Error("Failed to restore verification level")
+			emb.AddField("Verification Level", "Failed to restore: "+err.Error())
+		} else {
+			emb.AddField("Verification Level", fmt.Sprintf("Restored to %d", *previousLevel))
+		}
+	}
+	log.WithFields(log.Fields{"reason": reason}).Info("Raid mode disabled")
+	alertCommittee(s, emb)
+	return true
+}
+
+// expireRaidMode periodically turns off automatically enabled raid mode once joins have calmed down
+func expireRaidMode(s *discordgo.Session) {
+	for {
+		<-time.After(time.Minute)
+		raid.Lock()
+		quiet := raid.active && !raid.manual &&
+			(len(raid.joins) == 0 || time.Since(raid.joins[len(raid.joins)-1]) >= viper.GetDuration("raid.cooldown"))
+		raid.Unlock()
+		if quiet {
+			disableRaidMode(s, fmt.Sprintf("No joins for %s", viper.GetDuration("raid.cooldown")))
+		}
+	}
+}
+
+// alertCommittee posts to the mod log, or the committee events channel if there isn't one
+func alertCommittee(s *discordgo.Session, emb *embed.Embed) {
+	channels := viper.Get("discord.channels").(*config.Channels)
+	channelID := channels.ModLog
+	if channelID == "" {
+		channelID = channels.PrivateEvents
+	}
+	if _, err := s.ChannelMessageSendEmbed(channelID, emb.MessageEmbed); err != nil {
+		log.WithError(err).Error("Failed to alert committee")
+	}
+}
+
+// raidCommand turns raid mode on or off, or shows whether it's on
+func raidCommand(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
+	if !isCommittee(s, m) {
+		return
+	}
+	_, body := extractCommand(m.Content)
+	args := strings.Fields(body)[1:]
+	if len(args) == 0 {
+		raid.Lock()
+		status := "Raid mode is off"
+		if raid.active {
+			status = fmt.Sprintf("Raid mode has been on for %s, %d members haven't been welcomed", time.Since(raid.since).Round(time.Second), raid.suppressed)
+		}
+		raid.Unlock()
+		s.ChannelMessageSend(m.ChannelID, status)
+		return
+	}
+	switch strings.ToLower(args[0]) {
+	case "on":
+		if !enableRaidMode(s, true, "Enabled by "+m.Author.Mention()) {
+			s.ChannelMessageSend(m.ChannelID, "Raid mode is already on, it will now stay on until turned off")
+			return
+		}
+		log.WithContext(ctx).Info("Raid mode enabled manually")
+	case "off":
+		if !disableRaidMode(s, "Disabled by "+m.Author.Mention()) {
+			s.ChannelMessageSend(m.ChannelID, "Raid mode is already off")
+			return
+		}
+		log.WithContext(ctx).Info("Raid mode disabled manually")
+	default:
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Usage: *`!raid [on|off]`*"))
+		return
+	}
+	s.MessageReactionAdd(m.ChannelID, m.ID, string(approve))
+}
@@ -2,11 +2,12 @@ package commands
 
 import (
 	"context"
-	"fmt"
 	"strings"
+	"time"
 
 	"github.com/Strum355/log"
-	"github.com/UCCNetsoc/discord-bot/api"
+	"github.com/UCCNetsoc/discord-bot/activity"
+	"github.com/UCCNetsoc/discord-bot/config"
 	"github.com/UCCNetsoc/discord-bot/prometheus"
 	"github.com/bwmarrin/discordgo"
 	"github.com/dghubble/oauth1"
@@ -24,13 +25,18 @@ type Reaction string
 
 const (
 	twitter Reaction = "🇹"
+	undo    Reaction = "❌"
+)
+
+// Outlets entries can be cross-posted to
+const (
+	twitterOutlet = "twitter"
 )
 
 var (
 	helpStrings          = make(map[string]string)
 	committeeHelpStrings = make(map[string]string)
 	commandsMap          = make(map[string]func(context.Context, *discordgo.Session, *discordgo.MessageCreate))
-	reactionMap          = make(map[string]interface{}) // Maps message ids to content
 )
 
 type commandFunc func(context.Context, *discordgo.Session, *discordgo.MessageCreate)
@@ -53,9 +59,15 @@ func Register(s *discordgo.Session) {
 	command("register", "registers you as a member of the server", serverRegister, false)
 	command("online", "see how many people are online in minecraft.netsoc.co", online, false)
 	command("dig", "run a DNS query: dig TYPE DOMAIN [@RESOLVER]", digCommand, false)
+	command("notify", "get notified about events with a tag: notify [TAG]", notify, false)
+	command("top", "see who has sent the most messages, optionally in a channel or over a period such as 30d: top [#CHANNEL] [PERIOD]", top, false)
+	command("voice", "see who's in voice and how much each voice channel was used this week", voiceCommand, false)
+	command("mystats", "see how active you've been, optionally over a period such as 7d: mystats [PERIOD]", mystats, false)
+	command("optout", "stop tracking your activity for top and mystats, deleting what's been tracked", optout, false)
+	command("optin", "track your activity for top and mystats", optin, false)
 	command(
 		"event",
-		"send a message in the format: *`!event \"title\" \"yyyy-mm-dd\" \"description\"`* and make sure to have an image attached too.",
+		"send a message in the format: *`!event \"title\" \"yyyy-mm-dd hh:mm\" \"description\" \"location\" \"tag,tag\"`* (time, location and tags are optional, tagged events only mention members who *`!notify`* for them) and make sure to have at least one image attached too. Add *`--preview`* after the command to see it before it's published.",
 		addEvent,
 		true,
 	)
@@ -73,7 +85,7 @@ func Register(s *discordgo.Session) {
 	)
 	command(
 		"announce",
-		"send a message in the format *`!announce TEXT`*",
+		"send a message in the format *`!announce TEXT`*. Add *`--preview`* after the command to see it before it's published.",
 		addAnnouncement,
 		true,
 	)
@@ -90,6 +102,23 @@ func Register(s *discordgo.Session) {
 		true,
 	)
 	command("up", "check the status of various Netsoc hosted websites", checkUpCommand, true)
+	command(
+		"rolemenu",
+		"post a menu members react to for roles: *`!rolemenu CHANNEL_ID \"title\" EMOJI=ROLE, EMOJI=ROLE`*, roles can be names or ids",
+		createRoleMenu,
+		true,
+	)
+	command("delrolemenu", "delete a role menu: *`!delrolemenu MESSAGE_ID`*", deleteRoleMenu, true)
+	command("warn", "warn a member: *`!warn @user reason`*", warn, true)
+	command("mute", "mute a member, optionally for a duration such as 30m, 12h or 7d: *`!mute @user [duration] reason`*", mute, true)
+	command("unmute", "unmute a member: *`!unmute @user reason`*", unmute, true)
+	command("kick", "kick a member: *`!kick @user reason`*", kick, true)
+	command("ban", "ban a member, optionally for a duration such as 12h, 7d or 2w: *`!ban @user [duration] reason`*", ban, true)
+	command("unban", "unban a user: *`!unban USER_ID reason`*", unban, true)
+	command("stats", "summarise member growth and activity over a period such as 7d or 12w, 30d by default: *`!stats [period]`*", stats, true)
+	command("digest", "post the weekly digest of members, messages, events and site uptime now rather than waiting for it", digest, true)
+	command("raid", "turn raid mode on or off, which stops welcoming new members: *`!raid [on|off]`*", raidCommand, true)
+	command("cases", "list the moderation history of a member: *`!cases @user`*", cases, true)
 
 	// Setup APIs
 	twitterConfig := oauth1.NewConfig(viper.GetString("twitter.key"), viper.GetString("twitter.secret"))
@@ -97,10 +126,30 @@ func Register(s *discordgo.Session) {
 	httpClient := twitterConfig.Client(oauth1.NoContext, twitterToken)
 	twitterClient = twitterApi.NewClient(httpClient)
 
+	if viper.GetString("moderation.mute_role") == "" {
+		log.Warn("moderation.mute_role isn't set, mute and unmute won't work")
+	}
+
+	loadRoleMenus()
+	activity.Load()
+	go expirePendingPosts()
+	go expireDrafts(s)
+	go expireCases(s)
+	go pruneRecentMessages()
+	go expireRaidMode(s)
+	go postDigests(s)
+	go checkSites()
+
 	s.AddHandler(messageCreate)
 	s.AddHandler(messageReaction)
+	s.AddHandler(messageReactionRemove)
 	s.AddHandler(serverJoin)
 	s.AddHandler(memberLeave)
+	s.AddHandler(memberUpdate)
+	s.AddHandler(guildCreate)
+	s.AddHandler(messageUpdate)
+	s.AddHandler(messageDelete)
+	s.AddHandler(voiceStateUpdate)
 }
 
 // Called whenever a message is sent in a server the bot has access to
@@ -120,7 +169,15 @@ func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 			return
 		}
 	} else {
-		go prometheus.MessageCreate(m.GuildID, m.ChannelID)
+		// Cached first so messages automod deletes can still be shown in the audit log
+		cacheMessage(m.Message)
+		if automod(s, m) {
+			return
+		}
+		prometheus.MessageCreate(m.GuildID, m.ChannelID)
+		if m.GuildID == viper.Get("discord.servers").(*config.Servers).PublicServer {
+			activity.Message(m.GuildID, m.ChannelID, m.Author.ID)
+		}
 	}
 
 	if !strings.HasPrefix(m.Content, viper.GetString("bot.prefix")) {
@@ -148,59 +205,64 @@ func callCommand(s *discordgo.Session, m *discordgo.MessageCreate) {
 			"body":       body,
 		})
 		log.WithContext(ctx).Info("invoking standard command")
+		start := time.Now()
+		defer func() {
+			if r := recover(); r != nil {
+				log.WithContext(ctx).WithFields(log.Fields{"panic": r}).Error("command panicked")
+				s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Something went wrong running that command."))
+				prometheus.CommandInvoked(commandStr, prometheus.CommandPanic, time.Since(start))
+			}
+		}()
 		command(ctx, s, m)
+		prometheus.CommandInvoked(commandStr, prometheus.CommandOK, time.Since(start))
 		return
 	}
+	// Unknown commands aren't labelled with what was typed, so they can't flood the metrics with labels
+	prometheus.CommandInvoked(prometheus.CommandUnknown, prometheus.CommandUnknown, 0)
 }
 
 func messageReaction(s *discordgo.Session, m *discordgo.MessageReactionAdd) {
-	if m.UserID == s.State.User.ID {
+	if m.UserID == s.State.User.ID || roleMenuReaction(s, m.MessageReaction, true) || !isCommitteeReaction(s, m.MessageReaction) {
 		return
 	}
-	react := Reaction(m.MessageReaction.Emoji.Name)
-	if data, ok := reactionMap[m.MessageID]; ok {
-		if content, ok := data.(api.Entry); ok {
-			switch react {
-			case twitter:
-				mediaIds := []int64{}
-				image := content.GetImage()
-				if image.ImgData != nil {
-					// Contains image
-					// Upload image
-					mediaResponse, mediaHTTP, err := twitterClient.Media.Upload(&twitterApi.MediaUploadParams{
-						File:     image.ImgData.Bytes(),
-						MimeType: image.ImgHeader.Get("content-type"),
-					})
-					if err != nil {
-						log.WithError(err).Error("Failed to upload image")
-						return
-					}
-					mediaIds = append(mediaIds, mediaResponse.MediaID)
-					log.Info(mediaResponse.MediaIDString)
-					log.Info(mediaHTTP.Status)
-				}
-				// Send tweet
-				tweet, _, err := twitterClient.Statuses.Update(content.GetContent(), &twitterApi.StatusUpdateParams{
-					MediaIds: mediaIds,
-				})
-				if err != nil {
-					log.WithError(err).Error("Failed to send tweet")
-					return
-				}
-				log.Info(tweet.Text)
-				s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("https://twitter.com/%s/status/%d", tweet.User.ScreenName, tweet.ID))
-			}
-		}
+	ctx := reactionContext(m.MessageReaction)
+	if draftReaction(ctx, s, m.MessageReaction, true) {
+		return
+	}
+	switch Reaction(m.MessageReaction.Emoji.Name) {
+	case twitter:
+		confirmTweet(ctx, s, m.MessageReaction)
+	case undo:
+		retractTweet(ctx, s, m.MessageReaction)
+	}
+}
+
+func messageReactionRemove(s *discordgo.Session, m *discordgo.MessageReactionRemove) {
+	if m.UserID == s.State.User.ID || roleMenuReaction(s, m.MessageReaction, false) || !isCommitteeReaction(s, m.MessageReaction) {
+		return
+	}
+	ctx := reactionContext(m.MessageReaction)
+	if draftReaction(ctx, s, m.MessageReaction, false) {
+		return
+	}
+	switch Reaction(m.MessageReaction.Emoji.Name) {
+	case twitter:
+		retractTweet(ctx, s, m.MessageReaction)
 	}
 }
 
-func memberLeave(s *discordgo.Session, m *discordgo.GuildMemberRemove) {
-	prometheus.MemberLeave(m.User.ID)
+// isCommitteeReaction checks the reaction was made by committee in the events channel
+func isCommitteeReaction(s *discordgo.Session, r *discordgo.MessageReaction) bool {
+	channels := viper.Get("discord.channels").(*config.Channels)
+	return r.ChannelID == channels.PrivateEvents && isCommitteeUser(s, r.GuildID, r.UserID)
 }
 
-func messageDelete(s *discordgo.Session, m *discordgo.MessageDelete) {
-	// Check if its not a DM
-	if len(m.GuildID) != 0 {
-		prometheus.MessageDelete(m.GuildID, m.ChannelID)
-	}
+func reactionContext(r *discordgo.MessageReaction) context.Context {
+	return context.WithValue(context.Background(), log.Key, log.Fields{
+		"user_id":    r.UserID,
+		"channel_id": r.ChannelID,
+		"guild_id":   r.GuildID,
+		"message_id": r.MessageID,
+		"emoji":      r.Emoji.Name,
+	})
 }
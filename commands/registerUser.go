@@ -13,6 +13,7 @@ import (
 
 	"github.com/Strum355/log"
 	"github.com/UCCNetsoc/discord-bot/config"
+	"github.com/UCCNetsoc/discord-bot/database"
 	"github.com/UCCNetsoc/discord-bot/emails"
 	"github.com/bwmarrin/discordgo"
 	petname "github.com/dustinkirkland/golang-petname"
@@ -58,6 +59,7 @@ func initiatedRegistration(ctx context.Context, s *discordgo.Session, m *discord
 		log.WithContext(ctx).
 			WithError(err).
 			Error("failed to send verification email")
+		registrationFailed(ctx, m.Author.ID)
 		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Failed to send verification email. Please try again later or contact a SysAdmin"))
 		return initiatedRegistration
 	}
@@ -75,6 +77,7 @@ func initiatedRegistration(ctx context.Context, s *discordgo.Session, m *discord
 	log.WithContext(ctx).
 		WithFields(log.Fields{"status_code": response.StatusCode, "response": response.Body}).
 		Error("Sendgrid returned bad status code")
+	registrationFailed(ctx, m.Author.ID)
 	s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Failed to send verification email. Please try again later or contact a SysAdmin"))
 	return initiatedRegistration
 }
@@ -87,8 +90,10 @@ func submittedEmail(ctx context.Context, s *discordgo.Session, m *discordgo.Mess
 	if !ok {
 		// if we're here, shits either no bueno..or the bot was restarted since
 		log.WithContext(ctx).Error("expected verification token but none was found")
-		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("There was an issue verifying you, please contact a SysAdmin :("))
-		return submittedEmail
+		registrationFailed(ctx, m.Author.ID)
+		// Go back to asking for an email so a new token is sent, rather than failing on every message
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("There was an issue verifying you, please reply with your UCC email address to be sent a new token."))
+		return initiatedRegistration
 	}
 
 	if content != code {
@@ -110,6 +115,7 @@ func submittedEmail(ctx context.Context, s *discordgo.Session, m *discordgo.Mess
 				WithError(err).
 				WithFields(log.Fields{"role_id": roleID, "target_guild_id": servers.PublicServer}).
 				Error("failed to add role to user")
+			registrationFailed(ctx, m.Author.ID)
 			s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Failed registering you for the server, please contact a SysAdmin :("))
 			return submittedEmail
 		}
@@ -124,8 +130,18 @@ func submittedEmail(ctx context.Context, s *discordgo.Session, m *discordgo.Mess
 		MessageEmbed)
 
 	prometheus.MemberJoin(m.Author.ID)
+	if err := database.RecordMemberEvent(m.Author.ID, database.MemberRegistered); err != nil {
+		log.WithContext(ctx).WithError(err).Error("failed to record registration")
+	}
 
 	delete(registering, m.Author.ID)
 	delete(verifyCodes, m.Author.ID)
 	return nil
 }
+
+// registrationFailed records that registering failed for the user, for the committee's weekly digest
+func registrationFailed(ctx context.Context, userID string) {
+	if err := database.RecordMemberEvent(userID, database.MemberRegistrationFailed); err != nil {
+		log.WithContext(ctx).WithError(err).Error("failed to record failed registration")
+	}
+}
@@ -0,0 +1,213 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Strum355/log"
+	"github.com/UCCNetsoc/discord-bot/config"
+	"github.com/UCCNetsoc/discord-bot/database"
+	"github.com/UCCNetsoc/discord-bot/embed"
+	"github.com/bwmarrin/discordgo"
+	"github.com/spf13/viper"
+)
+
+var (
+	// Maps role menu message ids to the role given by each emoji
+	roleMenus     = make(map[string]map[string]string)
+	roleMenusLock sync.RWMutex
+
+	channelIDRegex = regexp.MustCompile(`^<?#?(\d+)>?$`)
+	emojiRegex     = regexp.MustCompile(`^<a?:(\w+:\d+)>$`)
+)
+
+// loadRoleMenus reads every role menu from the database so reactions can be handled without a query
+func loadRoleMenus() {
+	options, err := database.RoleMenus()
+	if err != nil {
+		log.WithError(err).Error("Failed to load role menus")
+		return
+	}
+	roleMenusLock.Lock()
+	defer roleMenusLock.Unlock()
+	for _, option := range options {
+		if _, ok := roleMenus[option.MessageID]; !ok {
+			roleMenus[option.MessageID] = make(map[string]string)
+		}
+		roleMenus[option.MessageID][option.Emoji] = option.RoleID
+	}
+}
+
+// createRoleMenu posts a message in the public server which members react to for roles
+// Command format: !rolemenu CHANNEL "Title" EMOJI=ROLE, EMOJI=ROLE
+func createRoleMenu(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
+	if !isCommittee(s, m) {
+		return
+	}
+	help := committeeHelpStrings["rolemenu"]
+	params := strings.Split(m.Content, "\"")
+	if len(params) != 3 {
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Error parsing command\n"+help))
+		return
+	}
+	args := strings.Fields(params[0])
+	if len(args) != 2 || !channelIDRegex.MatchString(args[1]) {
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Please provide the id of the channel to post the menu in\n"+help))
+		return
+	}
+	channelID := channelIDRegex.FindStringSubmatch(args[1])[1]
+	title := params[1]
+
+	servers := viper.Get("discord.servers").(*config.Servers)
+	if channel, err := s.Channel(channelID); err != nil || channel.GuildID != servers.PublicServer {
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Role menus can only be posted in channels on the public server"))
+		return
+	}
+	roles, err := s.GuildRoles(servers.PublicServer)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("failed to get public server roles")
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Failed to get the server's roles"))
+		return
+	}
+
+	options := []*database.RoleMenuOption{}
+	description := ""
+	for _, pair := range strings.Split(params[2], ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		emoji := strings.TrimSpace(parts[0])
+		role := findRole(roles, strings.TrimSpace(parts[1]))
+		if role == nil {
+			s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed(fmt.Sprintf("Couldn't find a role called `%s`", strings.TrimSpace(parts[1]))))
+			return
+		}
+		description += fmt.Sprintf("%s **%s**\n", emoji, role.Name)
+		// Custom emoji are reacted with as name:id
+		if matches := emojiRegex.FindStringSubmatch(emoji); matches != nil {
+			emoji = matches[1]
+		}
+		options = append(options, &database.RoleMenuOption{ChannelID: channelID, Emoji: emoji, RoleID: role.ID})
+	}
+	if len(options) == 0 {
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Please provide at least one emoji and role\n"+help))
+		return
+	}
+
+	menu, err := s.ChannelMessageSendEmbed(channelID, embed.NewEmbed().
+		SetTitle(title).
+		SetDescription(description).
+		SetFooter("React to get a role, remove your reaction to remove it").
+		MessageEmbed)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("failed to send role menu")
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Failed to post the role menu: "+err.Error()))
+		return
+	}
+	for _, option := range options {
+		option.MessageID = menu.ID
+	}
+	if err := database.SaveRoleMenu(options); err != nil {
+		log.WithContext(ctx).WithError(err).Error("failed to save role menu")
+		s.ChannelMessageDelete(channelID, menu.ID)
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Failed to save the role menu"))
+		return
+	}
+
+	roleMenusLock.Lock()
+	roleMenus[menu.ID] = make(map[string]string)
+	for _, option := range options {
+		roleMenus[menu.ID][option.Emoji] = option.RoleID
+	}
+	roleMenusLock.Unlock()
+
+	for _, option := range options {
+		if err := s.MessageReactionAdd(channelID, menu.ID, option.Emoji); err != nil {
+			log.WithContext(ctx).WithError(err).WithFields(log.Fields{"emoji": option.Emoji}).Error("failed to react to role menu")
+		}
+	}
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Role menu created, its id is `%s`", menu.ID))
+}
+
+// deleteRoleMenu stops a role menu from giving roles and deletes its message
+// Command format: !delrolemenu MESSAGE_ID
+func deleteRoleMenu(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
+	if !isCommittee(s, m) {
+		return
+	}
+	args := strings.Fields(m.Content)
+	if len(args) != 2 {
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Please provide the id of the role menu"))
+		return
+	}
+	messageID := args[1]
+	options, err := database.RoleMenus()
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("failed to get role menus")
+		return
+	}
+	channelID := ""
+	for _, option := range options {
+		if option.MessageID == messageID {
+			channelID = option.ChannelID
+		}
+	}
+	if channelID == "" {
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("There is no role menu with that id"))
+		return
+	}
+	if err := database.DeleteRoleMenu(messageID); err != nil {
+		log.WithContext(ctx).WithError(err).Error("failed to delete role menu")
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Failed to delete the role menu"))
+		return
+	}
+	roleMenusLock.Lock()
+	delete(roleMenus, messageID)
+	roleMenusLock.Unlock()
+	s.ChannelMessageDelete(channelID, messageID)
+	s.ChannelMessageSend(m.ChannelID, "Role menu deleted")
+}
+
+// roleMenuReaction gives or takes a role when a member reacts to a role menu
+// It returns false if the message isn't a role menu
+func roleMenuReaction(s *discordgo.Session, r *discordgo.MessageReaction, added bool) bool {
+	roleMenusLock.RLock()
+	menu, ok := roleMenus[r.MessageID]
+	roleMenusLock.RUnlock()
+	if !ok {
+		return false
+	}
+	roleID, ok := menu[r.Emoji.APIName()]
+	if !ok {
+		return true
+	}
+	servers := viper.Get("discord.servers").(*config.Servers)
+	var err error
+	if added {
+		err = s.GuildMemberRoleAdd(servers.PublicServer, r.UserID, roleID)
+	} else {
+		err = s.GuildMemberRoleRemove(servers.PublicServer, r.UserID, roleID)
+	}
+	if err != nil {
+		log.WithContext(reactionContext(r)).
+			WithError(err).
+			WithFields(log.Fields{"role_id": roleID, "added": added}).
+			Error("failed to update role from role menu")
+	}
+	return true
+}
+
+// findRole by id or case insensitive name
+func findRole(roles []*discordgo.Role, role string) *discordgo.Role {
+	role = strings.TrimSuffix(strings.TrimPrefix(role, "<@&"), ">")
+	for _, r := range roles {
+		if r.ID == role || strings.EqualFold(r.Name, strings.TrimPrefix(role, "@")) {
+			return r
+		}
+	}
+	return nil
+}
@@ -44,6 +44,11 @@ func serverJoin(s *discordgo.Session, m *discordgo.GuildMemberAdd) {
 	if m.GuildID != publicServer.ID {
 		return
 	}
+	logMemberJoin(s, m)
+	if recordJoin(s) {
+		log.WithContext(ctx).Info("Not welcoming member during raid mode")
+		return
+	}
 	// Handle join messages
 	messages := *viper.Get("discord.welcome_messages").(*[]string)
 	if len(messages) > 0 {
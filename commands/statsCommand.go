@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Strum355/log"
+	"github.com/UCCNetsoc/discord-bot/analytics"
+	"github.com/UCCNetsoc/discord-bot/config"
+	"github.com/UCCNetsoc/discord-bot/embed"
+	"github.com/bwmarrin/discordgo"
+	"github.com/spf13/viper"
+)
+
+// stats summarises the growth and activity of the public server over a period, 30 days by default
+func stats(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
+	if !isCommittee(s, m) {
+		return
+	}
+	_, body := extractCommand(m.Content)
+	args := strings.Fields(body)[1:]
+	period := 30 * 24 * time.Hour
+	if len(args) > 0 {
+		duration, ok := parseDuration(args[0])
+		if !ok || duration < 24*time.Hour {
+			s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Please give a period of at least a day such as 7d or 12w\n"+committeeHelpStrings["stats"]))
+			return
+		}
+		period = duration
+	}
+
+	servers := viper.Get("discord.servers").(*config.Servers)
+	guild, err := s.State.Guild(servers.PublicServer)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("failed to get public server")
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Failed to get the public server."))
+		return
+	}
+	report, err := analytics.Build(guild.MemberCount, period, 5)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("failed to build stats")
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Failed to get stats: "+err.Error()))
+		return
+	}
+	s.ChannelMessageSendEmbed(m.ChannelID, statsEmbed(report, guild.MemberCount).MessageEmbed)
+}
+
+func statsEmbed(report *analytics.Report, members int) *embed.Embed {
+	emb := embed.NewEmbed().
+		SetTitle("Server Stats").
+		SetDescription(fmt.Sprintf("%s to %s, compared to the %s before", report.From.Format(layoutIE), report.To.Format(layoutIE), formatAge(report.To.Sub(report.From)))).
+		SetColor(0x2196F3)
+	emb.AddField("Members", fmt.Sprintf("%d (%+d)", members, members-report.StartMembers)).
+		AddField("Joins", trend(report.Totals.Joins, report.Previous.Joins)).
+		AddField("Registrations", trend(report.Totals.Registrations, report.Previous.Registrations)).
+		AddField("Leaves", trend(report.Totals.Leaves, report.Previous.Leaves))
+
+	weeks := []string{}
+	for _, week := range report.Weekly {
+		weeks = append(weeks, fmt.Sprintf("%s: %d", week.Date.Format(layoutIE), week.Count))
+	}
+	// Only the most recent weeks fit in a field
+	if len(weeks) > 12 {
+		weeks = weeks[len(weeks)-12:]
+	}
+	if len(weeks) > 0 {
+		emb.AddField("Registrations Per Week", strings.Join(weeks, "\n"))
+	}
+
+	channels := []string{}
+	for i, channel := range report.Channels {
+		channels = append(channels, fmt.Sprintf("%d. <#%s>: %d messages", i+1, channel.Channel, channel.Messages))
+	}
+	if len(channels) > 0 {
+		emb.AddField("Most Active Channels", strings.Join(channels, "\n"))
+	}
+	return emb
+}
+
+// trend shows a count with how it changed from the previous period
+func trend(current, previous int) string {
+	switch {
+	case previous == 0 && current == 0:
+		return "0"
+	case previous == 0:
+		return fmt.Sprintf("%d (new)", current)
+	case current >= previous:
+		return fmt.Sprintf("%d (▲ %d%%)", current, (current-previous)*100/previous)
+	default:
+		return fmt.Sprintf("%d (▼ %d%%)", current, (previous-current)*100/previous)
+	}
+}
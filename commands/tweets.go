@@ -0,0 +1,333 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Strum355/log"
+	"github.com/UCCNetsoc/discord-bot/api"
+	"github.com/UCCNetsoc/discord-bot/database"
+	"github.com/UCCNetsoc/discord-bot/embed"
+	"github.com/UCCNetsoc/discord-bot/images"
+	"github.com/UCCNetsoc/discord-bot/prometheus"
+	"github.com/bwmarrin/discordgo"
+	twitterApi "github.com/ericm/go-twitter/twitter"
+	"github.com/spf13/viper"
+)
+
+// Twitter allows up to 4 images per tweet
+const maxTweetImages = 4
+
+var (
+	userMentionRegex    = regexp.MustCompile(`<@!?(\d+)>`)
+	roleMentionRegex    = regexp.MustCompile(`<@&(\d+)>`)
+	channelMentionRegex = regexp.MustCompile(`<#(\d+)>`)
+	customEmojiRegex    = regexp.MustCompile(`<a?:(\w+):\d+>`)
+	sentenceEndRegex    = regexp.MustCompile(`[.!?]+["')\]]*\s+|\n+`)
+)
+
+// offerTweet previews the twitter thread for an entry in the committee channel
+// and reacts to the command message so it can be confirmed
+func offerTweet(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, entry api.Entry) {
+	tweets := splitThread(tweetText(s, m.GuildID, entry.GetContent()), viper.GetInt("discord.charlimit"))
+	if len(tweets) == 0 {
+		return
+	}
+	emb := embed.NewEmbed().
+		SetTitle("Twitter Preview").
+		SetFooter(fmt.Sprintf("React with %s on the original message to post this to Twitter, remove it or react with %s to delete the tweet", twitter, undo))
+	tweetFields(emb, tweets)
+	post := &database.PendingPost{
+		MessageID: m.ID,
+		Tweets:    tweets,
+		Expires:   time.Now().Add(viper.GetDuration("twitter.confirm_expiry")),
+	}
+	// Only the image urls are kept, they're downloaded again when the tweet is confirmed
+	for i, image := range entry.GetImages() {
+		if i == maxTweetImages {
+			break
+		}
+		if i == 0 {
+			emb.SetImage(image.SourceURL)
+		}
+		post.ImageURLs = append(post.ImageURLs, image.ImgURL)
+	}
+	if err := database.SavePendingPost(post); err != nil {
+		log.WithContext(ctx).WithError(err).Error("Failed to save pending tweet")
+		return
+	}
+	if _, err := s.ChannelMessageSendEmbed(m.ChannelID, emb.MessageEmbed); err != nil {
+		log.WithContext(ctx).WithError(err).Error("Failed to send twitter preview")
+		return
+	}
+	s.MessageReactionAdd(m.ChannelID, m.ID, string(twitter))
+}
+
+// confirmTweet posts the pending thread for a message once a committee member reacts to it
+func confirmTweet(ctx context.Context, s *discordgo.Session, r *discordgo.MessageReaction) {
+	post, err := database.GetPendingPost(r.MessageID)
+	if errors.Is(err, database.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("Failed to get pending tweet")
+		return
+	}
+	if post.Expired() {
+		s.ChannelMessageSend(r.ChannelID, "This post can no longer be sent to Twitter as the confirmation has expired.")
+		database.DeletePendingPost(r.MessageID)
+		return
+	}
+	claimed, err := database.ClaimCrossPost(r.MessageID, twitterOutlet)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("Failed to claim tweet")
+		return
+	}
+	if !claimed {
+		// Already posted, nothing to do
+		return
+	}
+	tweets, err := postThread(post)
+	switch {
+	case err == nil:
+		prometheus.OutletPublish(twitterOutlet, prometheus.PublishSuccess)
+	case len(tweets) > 0:
+		prometheus.OutletPublish(twitterOutlet, prometheus.PublishPartial)
+	default:
+		prometheus.OutletPublish(twitterOutlet, prometheus.PublishFailure)
+	}
+	if err != nil && len(tweets) == 0 {
+		log.WithContext(ctx).WithError(err).Error("Failed to send tweet thread")
+		s.ChannelMessageSend(r.ChannelID, "Failed to send tweet: "+err.Error())
+		database.DeleteCrossPost(r.MessageID, twitterOutlet)
+		return
+	}
+	ids := []string{}
+	for _, tweet := range tweets {
+		ids = append(ids, tweet.IDStr)
+	}
+	if err := database.SetCrossPostIDs(r.MessageID, twitterOutlet, ids); err != nil {
+		log.WithContext(ctx).WithError(err).Error("Failed to store tweet ids")
+	}
+	if len(tweets) == 0 {
+		return
+	}
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("Failed to send tweet thread")
+		s.ChannelMessageSend(r.ChannelID, "Failed to send the full thread: "+err.Error())
+	}
+	s.ChannelMessageSend(r.ChannelID, fmt.Sprintf("https://twitter.com/%s/status/%d", tweets[0].User.ScreenName, tweets[0].ID))
+}
+
+// retractTweet deletes the thread posted for a message
+func retractTweet(ctx context.Context, s *discordgo.Session, r *discordgo.MessageReaction) {
+	post, err := database.GetCrossPost(r.MessageID, twitterOutlet)
+	if errors.Is(err, database.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("Failed to get tweet")
+		return
+	}
+	// The thread is claimed before it's sent, so there are no ids until it has been posted
+	if len(post.PostIDs) == 0 {
+		s.ChannelMessageSend(r.ChannelID, "The tweet is still being sent, try deleting it again once it has been posted.")
+		return
+	}
+	// Delete replies first so the thread is never left without its start
+	for i := len(post.PostIDs) - 1; i >= 0; i-- {
+		id, err := strconv.ParseInt(post.PostIDs[i], 10, 64)
+		if err != nil {
+			continue
+		}
+		if _, _, err := twitterClient.Statuses.Destroy(id, nil); err != nil {
+			log.WithContext(ctx).WithError(err).WithFields(log.Fields{"tweet_id": id}).Error("Failed to delete tweet")
+			s.ChannelMessageSend(r.ChannelID, "Failed to delete tweet: "+err.Error())
+			return
+		}
+	}
+	if err := database.DeleteCrossPost(r.MessageID, twitterOutlet); err != nil {
+		log.WithContext(ctx).WithError(err).Error("Failed to delete tweet record")
+	}
+	s.ChannelMessageSend(r.ChannelID, "Successfully deleted tweet")
+}
+
+// tweetFields adds a field to the embed for each tweet in the thread
+func tweetFields(emb *embed.Embed, tweets []string) {
+	for i, tweet := range tweets {
+		if len(emb.Fields) == embed.EmbedLimitField {
+			break
+		}
+		emb.AddField(fmt.Sprintf("Tweet %d/%d", i+1, len(tweets)), tweet)
+	}
+}
+
+// postThread sends each tweet as a reply to the previous one, with the images on the first
+// It returns the tweets which were sent, which may be only part of the thread if err is not nil
+func postThread(t *database.PendingPost) ([]*twitterApi.Tweet, error) {
+	mediaIds := []int64{}
+	for _, url := range t.ImageURLs {
+		image, err := api.FetchImage(url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download image: %w", err)
+		}
+		data, contentType, err := images.ForTwitter(image.ImgData.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("failed to resize image: %w", err)
+		}
+		mediaResponse, _, err := twitterClient.Media.Upload(&twitterApi.MediaUploadParams{
+			File:     data,
+			MimeType: contentType,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload image: %w", err)
+		}
+		mediaIds = append(mediaIds, mediaResponse.MediaID)
+	}
+	tweets := []*twitterApi.Tweet{}
+	for i, text := range t.Tweets {
+		params := &twitterApi.StatusUpdateParams{}
+		if i == 0 {
+			params.MediaIds = mediaIds
+		} else {
+			params.InReplyToStatusID = tweets[i-1].ID
+		}
+		tweet, _, err := twitterClient.Statuses.Update(text, params)
+		if err != nil {
+			return tweets, fmt.Errorf("failed to send tweet %d/%d: %w", i+1, len(t.Tweets), err)
+		}
+		tweets = append(tweets, tweet)
+	}
+	return tweets, nil
+}
+
+// expirePendingPosts periodically removes pending posts which can no longer be confirmed
+func expirePendingPosts() {
+	for {
+		// Keep expired posts around for a day so late reactions get a reply
+		purged, err := database.PurgePendingPosts(time.Now().Add(-24 * time.Hour))
+		if err != nil {
+			log.WithError(err).Error("Failed to purge expired pending posts")
+		} else if purged > 0 {
+			log.WithFields(log.Fields{"purged": purged}).Info("Purged expired pending posts")
+		}
+		<-time.After(time.Hour)
+	}
+}
+
+// tweetText replaces discord mentions and custom emoji with plain text
+func tweetText(s *discordgo.Session, guildID, content string) string {
+	content = userMentionRegex.ReplaceAllStringFunc(content, func(mention string) string {
+		id := userMentionRegex.FindStringSubmatch(mention)[1]
+		if member, err := s.State.Member(guildID, id); err == nil {
+			if member.Nick != "" {
+				return "@" + member.Nick
+			}
+			return "@" + member.User.Username
+		}
+		if user, err := s.User(id); err == nil {
+			return "@" + user.Username
+		}
+		return ""
+	})
+	content = roleMentionRegex.ReplaceAllStringFunc(content, func(mention string) string {
+		id := roleMentionRegex.FindStringSubmatch(mention)[1]
+		if role, err := s.State.Role(guildID, id); err == nil {
+			return "@" + role.Name
+		}
+		return ""
+	})
+	content = channelMentionRegex.ReplaceAllStringFunc(content, func(mention string) string {
+		id := channelMentionRegex.FindStringSubmatch(mention)[1]
+		if channel, err := s.State.Channel(id); err == nil {
+			return "#" + channel.Name
+		}
+		return ""
+	})
+	content = customEmojiRegex.ReplaceAllString(content, ":$1:")
+	for _, symbol := range viper.GetStringSlice("api.remove_symbols") {
+		content = strings.ReplaceAll(content, symbol, "")
+	}
+	return strings.TrimSpace(content)
+}
+
+// splitThread splits content into tweets of at most limit characters, breaking at
+// sentence boundaries where possible. Tweets are numbered if there is more than one
+func splitThread(content string, limit int) []string {
+	if utf8.RuneCountInString(content) <= limit {
+		if len(content) == 0 {
+			return nil
+		}
+		return []string{content}
+	}
+	// Reserve room for the numbering suffix, growing it until the tweet count's digits fit
+	tweets := []string{}
+	for digits := 1; ; digits++ {
+		suffix := len(fmt.Sprintf(" (%s/%s)", strings.Repeat("9", digits), strings.Repeat("9", digits)))
+		tweets = packSentences(splitSentences(content), limit-suffix)
+		if len(fmt.Sprint(len(tweets))) <= digits {
+			break
+		}
+	}
+	for i := range tweets {
+		tweets[i] = fmt.Sprintf("%s (%d/%d)", tweets[i], i+1, len(tweets))
+	}
+	return tweets
+}
+
+// splitSentences splits content after sentence-ending punctuation and newlines
+func splitSentences(content string) []string {
+	sentences := []string{}
+	start := 0
+	for _, loc := range sentenceEndRegex.FindAllStringIndex(content, -1) {
+		if sentence := strings.TrimSpace(content[start:loc[1]]); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		start = loc[1]
+	}
+	if sentence := strings.TrimSpace(content[start:]); sentence != "" {
+		sentences = append(sentences, sentence)
+	}
+	return sentences
+}
+
+// packSentences greedily joins sentences into chunks of at most limit characters
+// Sentences longer than limit are broken up by word, and words by character
+func packSentences(sentences []string, limit int) []string {
+	chunks := []string{}
+	current := ""
+	add := func(part, sep string) {
+		if current == "" {
+			current = part
+		} else if utf8.RuneCountInString(current+sep+part) <= limit {
+			current += sep + part
+		} else {
+			chunks = append(chunks, current)
+			current = part
+		}
+	}
+	for _, sentence := range sentences {
+		if utf8.RuneCountInString(sentence) <= limit {
+			add(sentence, " ")
+			continue
+		}
+		for _, word := range strings.Fields(sentence) {
+			for utf8.RuneCountInString(word) > limit {
+				runes := []rune(word)
+				add(string(runes[:limit]), " ")
+				word = string(runes[limit:])
+			}
+			add(word, " ")
+		}
+	}
+	if current != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
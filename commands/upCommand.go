@@ -7,6 +7,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Strum355/log"
+	"github.com/UCCNetsoc/discord-bot/database"
 	"github.com/UCCNetsoc/discord-bot/embed"
 
 	"github.com/bwmarrin/discordgo"
@@ -35,17 +37,7 @@ func checkUpCommand(ctx context.Context, s *discordgo.Session, m *discordgo.Mess
 }
 
 func checkStatuses(s *discordgo.Session, m *discordgo.MessageCreate, sites []string) {
-	// Create a channel to receive the status checks, whenever they complete
-	statuses := make(chan statusCheck)
-	// Run each status check on a separate goroutine as to not block each other
-	for _, site := range sites {
-		go checkStatus(site, 3, statuses)
-	}
-
-	results := make([]statusCheck, 0)
-	for i := 0; i < len(sites); i++ {
-		results = append(results, <-statuses)
-	}
+	results := runStatusChecks(sites)
 
 	emb := embed.NewEmbed().SetTitle("Website Statuses")
 	for _, result := range results {
@@ -61,6 +53,38 @@ func checkStatuses(s *discordgo.Session, m *discordgo.MessageCreate, sites []str
 	s.ChannelMessageSendEmbed(m.ChannelID, emb.MessageEmbed)
 }
 
+// checkSites periodically checks the status of the websites, so their uptime can be included in the weekly digest
+func checkSites() {
+	for {
+		<-time.After(viper.GetDuration("netsoc.check_interval"))
+		for _, result := range runStatusChecks(strings.Split(viper.GetString("netsoc.sites"), ",")) {
+			err := database.RecordSiteCheck(result.Site, result.Success, time.Duration(result.Time)*time.Millisecond, time.Now())
+			if err != nil {
+				log.WithError(err).WithFields(log.Fields{"site": result.Site}).Error("Failed to record site check")
+			}
+		}
+		if err := database.DeleteSiteChecks(time.Now().Add(-viper.GetDuration("netsoc.check_retention"))); err != nil {
+			log.WithError(err).Error("Failed to delete old site checks")
+		}
+	}
+}
+
+// runStatusChecks checks the status of each site at the same time
+func runStatusChecks(sites []string) []statusCheck {
+	// Create a channel to receive the status checks, whenever they complete
+	statuses := make(chan statusCheck)
+	// Run each status check on a separate goroutine as to not block each other
+	for _, site := range sites {
+		go checkStatus(site, 3, statuses)
+	}
+
+	results := make([]statusCheck, 0)
+	for i := 0; i < len(sites); i++ {
+		results = append(results, <-statuses)
+	}
+	return results
+}
+
 // Check the up status of a website, returning an error if not up - checks if can connect and response is 200
 func checkStatus(site string, retryCount int, statuses chan statusCheck) {
 	var timeTaken int64 = 0
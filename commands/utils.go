@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"strings"
 	"time"
 
 	"github.com/UCCNetsoc/discord-bot/embed"
@@ -18,8 +19,13 @@ var (
 
 // Checks if the given member is within the committee discord
 func isCommittee(s *discordgo.Session, m *discordgo.MessageCreate) bool {
-	if m.GuildID != "" {
-		return m.GuildID == (viper.Get("discord.servers").(*config.Servers).CommitteeServer)
+	return isCommitteeUser(s, m.GuildID, m.Author.ID)
+}
+
+// Checks if the given user is within the committee discord, guildID being where they're acting from
+func isCommitteeUser(s *discordgo.Session, guildID, userID string) bool {
+	if guildID != "" {
+		return guildID == (viper.Get("discord.servers").(*config.Servers).CommitteeServer)
 	}
 
 	var err error
@@ -35,7 +41,7 @@ func isCommittee(s *discordgo.Session, m *discordgo.MessageCreate) bool {
 		lastUpdated = time.Now()
 	}
 
-	return memberContains(committeeMembers, m.Author.ID)
+	return memberContains(committeeMembers, userID)
 }
 
 // Checks if the given member has been granted the Captain role 
@@ -67,6 +73,20 @@ func memberContains(members []*discordgo.Member, userID string) bool {
 	return found
 }
 
+// configPairs parses a config value in the format "key:value,key:value" into a map
+// Keys are lowercased so they can be matched against user input
+func configPairs(key string) map[string]string {
+	pairs := map[string]string{}
+	for _, pair := range strings.Split(viper.GetString(key), ",") {
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		pairs[strings.ToLower(strings.TrimSpace(parts[0]))] = strings.TrimSpace(parts[1])
+	}
+	return pairs
+}
+
 func errorEmbed(message string) *discordgo.MessageEmbed {
 	return embed.NewEmbed().SetTitle("❗ ERROR ❗").SetDescription(message).MessageEmbed
 }
@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Strum355/log"
+	"github.com/UCCNetsoc/discord-bot/database"
+	"github.com/UCCNetsoc/discord-bot/embed"
+	"github.com/UCCNetsoc/discord-bot/voice"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Called whenever a member joins, leaves or moves between voice channels
+func voiceStateUpdate(s *discordgo.Session, v *discordgo.VoiceStateUpdate) {
+	voice.Update(s, v.VoiceState)
+}
+
+// voiceCommand shows who is in voice and how much each voice channel was used in the past week
+func voiceCommand(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
+	channels, err := voice.Channels(s)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("failed to get voice channels")
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Failed to get the voice channels."))
+		return
+	}
+	usages, err := database.VoiceUsages(time.Now().AddDate(0, 0, -7), time.Now())
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("failed to get voice usage")
+		s.ChannelMessageSendEmbed(m.ChannelID, errorEmbed("Failed to get voice usage."))
+		return
+	}
+
+	emb := embed.NewEmbed().
+		SetTitle("Voice").
+		SetColor(0x9C27B0)
+	if len(channels) == 0 {
+		emb.SetDescription("Nobody is in voice right now")
+	}
+	// One field is kept for the week's usage
+	if len(channels) > embed.EmbedLimitField-1 {
+		channels = channels[:embed.EmbedLimitField-1]
+	}
+	for _, channel := range channels {
+		names := []string{}
+		for _, member := range channel.Members {
+			names = append(names, voice.Name(member))
+		}
+		emb.AddField(fmt.Sprintf("%s (%d)", channel.Name, len(channel.Members)), strings.Join(names, "\n"))
+	}
+	lines := []string{}
+	for _, usage := range usages {
+		lines = append(lines, fmt.Sprintf("<#%s>: %s over %d sessions", usage.Channel, formatVoiceTime(usage.Seconds), usage.Sessions))
+	}
+	if len(lines) > 0 {
+		emb.AddField("This Week", strings.Join(lines, "\n"))
+	}
+	s.ChannelMessageSendEmbed(m.ChannelID, emb.MessageEmbed)
+}
+
+// formatVoiceTime formats a number of seconds as hours and minutes
+func formatVoiceTime(seconds int) string {
+	d := time.Duration(seconds) * time.Second
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+}
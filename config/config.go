@@ -20,6 +20,10 @@ type Channels struct {
 	PublicGeneral       string `json:"public_general"`       // On public server
 	PrivateEvents       string `json:"private_events"`       // On committee server
 	Captains 			string `json:"captains"`
+	ModLog              string `json:"mod_log"`              // On committee server
+	AuditLog            string `json:"audit_log"`            // On committee server
+	MemberLog           string `json:"member_log"`           // On committee server
+	Digest              string `json:"digest"`               // On committee server
 }
 
 // InitConfig sets up viper and consul.
@@ -34,7 +38,7 @@ func InitConfig() error {
 	)
 	viper.Set(
 		"discord.channels",
-		&Channels{PublicAnnouncements: viper.GetString("discord.public.channel"), PrivateEvents: viper.GetString("discord.committee.channel"), PublicGeneral: viper.GetString("discord.public.general"), Captains: viper.GetString("discord.sports.captains")},
+		&Channels{PublicAnnouncements: viper.GetString("discord.public.channel"), PrivateEvents: viper.GetString("discord.committee.channel"), PublicGeneral: viper.GetString("discord.public.general"), Captains: viper.GetString("discord.sports.captains"), ModLog: viper.GetString("discord.committee.modlog"), AuditLog: viper.GetString("discord.committee.auditlog"), MemberLog: viper.GetString("discord.committee.memberlog"), Digest: viper.GetString("discord.committee.digest")},
 	)
 	welcomeMessages := []string{}
 	for _, message := range strings.Split(viper.GetString("discord.public.welcome"), ",") {
@@ -7,6 +7,7 @@ func initDefaults() {
 	viper.SetDefault("bot.prefix", "!")
 	viper.SetDefault("bot.quote.default_message_weight", 1)
 	viper.SetDefault("bot.version", "development")
+	viper.SetDefault("bot.timezone", "Europe/Dublin")
 	// Discord
 	viper.SetDefault("discord.token", "") // GitHub scrapers be like -.-
 	viper.SetDefault("discord.servers", &Servers{})
@@ -18,6 +19,10 @@ func initDefaults() {
 	viper.SetDefault("discord.public.welcome", "")
 	viper.SetDefault("discord.committee.server", "")
 	viper.SetDefault("discord.committee.channel", "")
+	viper.SetDefault("discord.committee.modlog", "")
+	viper.SetDefault("discord.committee.auditlog", "")
+	viper.SetDefault("discord.committee.memberlog", "")
+	viper.SetDefault("discord.committee.digest", "")
 	viper.SetDefault("discord.sports.server", "")
 	viper.SetDefault("discord.sports.captains", "")
 
@@ -25,9 +30,49 @@ func initDefaults() {
 
 	viper.SetDefault("discord.roles", "")
 	viper.SetDefault("discord.autoregister", true)
-	viper.SetDefault("discord.charlimit", 280) // Limit for event description
+	viper.SetDefault("discord.charlimit", 280) // Character limit for a single tweet
 	viper.SetDefault("discord.quote_blacklist", &[]string{})
 
+	// Events
+	viper.SetDefault("events.embed", true) // Post events as embeds rather than plain text
+	viper.SetDefault("events.website", "https://netsoc.co/events")
+	viper.SetDefault("events.colours", "default:2196F3,workshop:4CAF50,talk:00BCD4,social:FF9800,gaming:9C27B0")
+	viper.SetDefault("events.tag_roles", "") // Notification role for each tag, e.g. workshop:ROLE_ID,social:ROLE_ID
+	// Announcements
+	viper.SetDefault("announce.approvals", 0) // Committee approvals needed before publishing, 0 publishes immediately
+	viper.SetDefault("announce.draft_expiry", "48h")
+
+	// Moderation
+	viper.SetDefault("moderation.mute_role", "") // Role on the public server which can't send messages
+	// Automod, each rule's action is a comma separated list of delete, warn and timeout. Empty disables the rule, as they all are by default
+	viper.SetDefault("automod.exempt_roles", "")
+	viper.SetDefault("automod.timeout", "10m")
+	viper.SetDefault("automod.spam.action", "")
+	viper.SetDefault("automod.spam.count", 4)
+	viper.SetDefault("automod.spam.window", "30s")
+	viper.SetDefault("automod.invites.action", "")
+	viper.SetDefault("automod.mentions.action", "")
+	viper.SetDefault("automod.mentions.max", 6)
+	viper.SetDefault("automod.words.action", "")
+	viper.SetDefault("automod.words.patterns", []string{})
+
+	// Audit log of edited and deleted messages
+	viper.SetDefault("audit.ignore_channels", "") // Comma separated channel ids which aren't logged
+
+	// Member log
+	viper.SetDefault("members.new_account_age", "168h") // Accounts younger than this are flagged when they join
+
+	// Raid mode, enabled when raid.joins members join within raid.window
+	viper.SetDefault("raid.joins", 10)
+	viper.SetDefault("raid.window", "1m")
+	viper.SetDefault("raid.cooldown", "15m")       // Raid mode turns off after no joins for this long, unless enabled by command
+	viper.SetDefault("raid.verification_level", 0) // Verification level to raise the public server to, 0 leaves it unchanged
+
+	// Per user activity for leaderboards
+	viper.SetDefault("activity.track_users", true)
+	viper.SetDefault("activity.opt_in", true) // Only track members who use !optin, rather than everyone who hasn't used !optout
+	viper.SetDefault("activity.save_interval", "1m")
+
 	// Sendgrid
 	viper.SetDefault("sendgrid.token", "")
 	// Twitter
@@ -35,20 +80,45 @@ func initDefaults() {
 	viper.SetDefault("twitter.secret", "")
 	viper.SetDefault("twitter.access.key", "")
 	viper.SetDefault("twitter.access.secret", "")
+	viper.SetDefault("twitter.confirm_expiry", "24h") // How long committee have to confirm a tweet
 	// Rest API
 	viper.SetDefault("api.port", 80)
 	viper.SetDefault("api.event_query_limit", 20)
 	viper.SetDefault("api.announcement_query_limit", 20)
 	viper.SetDefault("api.public_message_cutoff", 10)
 	viper.SetDefault("api.remove_symbols", []string{"@everyone", "@here"})
+	viper.SetDefault("api.public_url", "")      // Base url the api is reachable at, media urls point at discord if empty
+	viper.SetDefault("api.stats_token", "")     // Bearer token required for the /stats endpoints, which are unavailable if empty
+	viper.SetDefault("api.stats_public", false) // Serve the /stats endpoints without a token
+	// Media store for event posters
+	viper.SetDefault("media.dir", "data/media")
+	// Images attached to events and announcements
+	viper.SetDefault("images.types", []string{"image/jpeg", "image/png", "image/gif"})
+	viper.SetDefault("images.max_size", 8*1024*1024)
+	viper.SetDefault("images.max_count", 4)
+	viper.SetDefault("images.web_width", 1280)
+	viper.SetDefault("images.thumbnail_width", 320)
 	// Up sites
 	viper.SetDefault("netsoc.sites", "https://uccexpress.ie,https://netsoc.co,https://motley.ie,https://admin.netsoc.co,https://hlm.netsoc.co,https://uccnetsoc.netsoc.co,https://wiki.netsoc.co")
+	viper.SetDefault("netsoc.check_interval", "5m")    // How often the sites are checked for their uptime
+	viper.SetDefault("netsoc.check_retention", "720h") // How long site checks are kept
 	viper.SetDefault("minecraft.host", "games.vm.netsoc.co:1194")
+	// Weekly digest posted to the committee digest channel
+	viper.SetDefault("digest.day", "Monday")
+	viper.SetDefault("digest.time", "09:00") // In bot.timezone
 	// Prometheus exporter
-	viper.SetDefault("prom.port", 2112)
-	viper.SetDefault("prom.dbname", "promexporter")
+	viper.SetDefault("prom.port", 2112)           // Can be the same as api.port to serve both from one listener
+	viper.SetDefault("prom.save_interval", "30s") // How often message counts are saved to the database
+	// HTTP server
+	viper.SetDefault("server.shutdown_timeout", "10s") // How long requests in progress have to finish on exit
+
 	// Database
+	viper.SetDefault("database.driver", "mysql")           // mysql or sqlite
+	viper.SetDefault("database.timeout", "5s")             // How long a single query or transaction can take
+	viper.SetDefault("database.auto_migrate", true)        // Migrate the schema on startup, otherwise the bot refuses to start until run with -migrate
+	viper.SetDefault("sqlite.path", "data/discord-bot.db") // ":memory:" for a throwaway database
 	viper.SetDefault("mysql.url", "mysql.netsoc.local:3306")
 	viper.SetDefault("mysql.username", "root")
 	viper.SetDefault("mysql.password", "password")
+	viper.SetDefault("mysql.dbname", "") // Falls back to the deprecated prom.dbname, then promexporter
 }
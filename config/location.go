@@ -0,0 +1,28 @@
+package config
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Strum355/log"
+	"github.com/spf13/viper"
+)
+
+var (
+	location     *time.Location
+	locationOnce sync.Once
+)
+
+// Location returns the bot's timezone, bot.timezone, which dates are given and shown in
+// UTC is used if it can't be loaded, such as when the system has no timezone database
+func Location() *time.Location {
+	locationOnce.Do(func() {
+		loc, err := time.LoadLocation(viper.GetString("bot.timezone"))
+		if err != nil {
+			log.WithError(err).WithFields(log.Fields{"timezone": viper.GetString("bot.timezone")}).Error("Failed to load timezone, using UTC")
+			loc = time.UTC
+		}
+		location = loc
+	})
+	return location
+}
@@ -0,0 +1,141 @@
+package database
+
+import "time"
+
+// UserActivity is how active a user was in a channel on a day
+type UserActivity struct {
+	Day          time.Time
+	Server       string
+	Channel      string
+	UserID       string
+	Messages     int
+	VoiceSeconds int
+}
+
+// UserTotal is a user's activity summed over a period
+type UserTotal struct {
+	UserID       string
+	Messages     int
+	VoiceSeconds int
+}
+
+// AddUserActivity adds to each user's activity in the channels on the days
+func AddUserActivity(activities []*UserActivity) error {
+	return transaction(func(tx *tx) error {
+		for _, a := range activities {
+			_, err := tx.exec(
+				"INSERT INTO user_activity(day, server, channel, user_id, messages, voice_seconds) VALUES(?, ?, ?, ?, ?, ?) "+
+					sqlDialect.upsert+" messages = messages + ?, voice_seconds = voice_seconds + ?",
+				dayOf(a.Day), a.Server, a.Channel, a.UserID, a.Messages, a.VoiceSeconds, a.Messages, a.VoiceSeconds,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// TopUsers returns the users who sent the most messages since from, only counting the channel if it isn't empty
+func TopUsers(from time.Time, channel string, limit int) ([]*UserTotal, error) {
+	q := "SELECT user_id, SUM(messages) AS total, SUM(voice_seconds) FROM user_activity WHERE day >= ? "
+	args := []interface{}{dayOf(from)}
+	if channel != "" {
+		q += "AND channel = ? "
+		args = append(args, channel)
+	}
+	q += "GROUP BY user_id ORDER BY total DESC LIMIT ?"
+	args = append(args, limit)
+	rows, err := query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	totals := []*UserTotal{}
+	for rows.Next() {
+		t := &UserTotal{}
+		if err := rows.Scan(&t.UserID, &t.Messages, &t.VoiceSeconds); err != nil {
+			return nil, err
+		}
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
+
+// UserTotals returns a user's activity since from, along with their rank by messages sent
+func UserTotals(userID string, from time.Time) (*UserTotal, int, error) {
+	day := dayOf(from)
+	t := &UserTotal{UserID: userID}
+	err := queryRow(
+		"SELECT COALESCE(SUM(messages), 0), COALESCE(SUM(voice_seconds), 0) FROM user_activity WHERE user_id = ? AND day >= ?",
+		userID, day,
+	).Scan(&t.Messages, &t.VoiceSeconds)
+	if err != nil {
+		return nil, 0, err
+	}
+	var ahead int
+	err = queryRow(
+		"SELECT COUNT(*) FROM (SELECT SUM(messages) AS total FROM user_activity WHERE day >= ? GROUP BY user_id) totals WHERE total > ?",
+		day, t.Messages,
+	).Scan(&ahead)
+	return t, ahead + 1, err
+}
+
+// UserChannels returns the channels a user sent the most messages in since from
+func UserChannels(userID string, from time.Time, limit int) ([]*ChannelActivity, error) {
+	rows, err := query(
+		"SELECT server, channel, SUM(messages) AS total FROM user_activity WHERE user_id = ? AND day >= ? GROUP BY server, channel ORDER BY total DESC LIMIT ?",
+		userID, dayOf(from), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	activities := []*ChannelActivity{}
+	for rows.Next() {
+		a := &ChannelActivity{}
+		if err := rows.Scan(&a.Server, &a.Channel, &a.Messages); err != nil {
+			return nil, err
+		}
+		activities = append(activities, a)
+	}
+	return activities, rows.Err()
+}
+
+// DeleteUserActivity removes all of a user's activity, keeping their voice sessions without who they were
+func DeleteUserActivity(userID string) error {
+	return transaction(func(tx *tx) error {
+		if _, err := tx.exec("DELETE FROM user_activity WHERE user_id = ?", userID); err != nil {
+			return err
+		}
+		_, err := tx.exec("UPDATE voice_sessions SET user_id = '' WHERE user_id = ?", userID)
+		return err
+	})
+}
+
+// ActivityPreferences returns whether each user who has chosen wants their activity tracked
+func ActivityPreferences() (map[string]bool, error) {
+	rows, err := query("SELECT user_id, tracked FROM activity_preferences")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	preferences := make(map[string]bool)
+	for rows.Next() {
+		var (
+			userID  string
+			tracked bool
+		)
+		if err := rows.Scan(&userID, &tracked); err != nil {
+			return nil, err
+		}
+		preferences[userID] = tracked
+	}
+	return preferences, rows.Err()
+}
+
+// SetActivityPreference stores whether a user wants their activity tracked
+func SetActivityPreference(userID string, tracked bool) error {
+	_, err := exec("REPLACE INTO activity_preferences(user_id, tracked) VALUES(?, ?)", userID, tracked)
+	return err
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"time"
+
+	"github.com/UCCNetsoc/discord-bot/config"
+)
+
+// Kinds of member events
+const (
+	MemberJoined             = "join"
+	MemberRegistered         = "register"
+	MemberLeft               = "leave"
+	MemberRegistrationFailed = "register_failed" // Such as the verification email not sending or the role not being given
+)
+
+// MemberEvent is a member joining, registering, failing to register or leaving the public server
+type MemberEvent struct {
+	UserID  string
+	Kind    string
+	Created time.Time
+}
+
+// ChannelActivity is the number of messages sent in a channel over a period
+type ChannelActivity struct {
+	Server   string
+	Channel  string
+	Messages int
+}
+
+// activityDay is the layout of the days message activity is grouped by
+const activityDay = "2006-01-02"
+
+// dayOf returns the day t is on in the bot's timezone, which is what days are reported in
+func dayOf(t time.Time) string {
+	return t.In(config.Location()).Format(activityDay)
+}
+
+// RecordMemberEvent stores that a member joined, registered or left
+func RecordMemberEvent(userID, kind string) error {
+	_, err := exec("INSERT INTO member_events(user_id, kind, created) VALUES(?, ?, ?)", userID, kind, time.Now().UTC())
+	return err
+}
+
+// MemberEvents returns the member events between from and to, oldest first
+func MemberEvents(from, to time.Time) ([]*MemberEvent, error) {
+	rows, err := query(
+		"SELECT user_id, kind, created FROM member_events WHERE created >= ? AND created < ? ORDER BY created",
+		from.UTC(), to.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := []*MemberEvent{}
+	for rows.Next() {
+		e := &MemberEvent{}
+		if err := rows.Scan(&e.UserID, &e.Kind, &e.Created); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// ChannelActivities returns the server's channels with the most messages sent on the days between from and to, most active first
+func ChannelActivities(server string, from, to time.Time, limit int) ([]*ChannelActivity, error) {
+	rows, err := query(
+		"SELECT server, channel, SUM(messages) AS total FROM message_activity WHERE server = ? AND day >= ? AND day <= ? GROUP BY server, channel ORDER BY total DESC LIMIT ?",
+		server, dayOf(from), dayOf(to), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	activities := []*ChannelActivity{}
+	for rows.Next() {
+		a := &ChannelActivity{}
+		if err := rows.Scan(&a.Server, &a.Channel, &a.Messages); err != nil {
+			return nil, err
+		}
+		activities = append(activities, a)
+	}
+	return activities, rows.Err()
+}
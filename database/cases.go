@@ -0,0 +1,86 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Moderation actions recorded as cases
+const (
+	ActionWarn   = "warn"
+	ActionMute   = "mute"
+	ActionUnmute = "unmute"
+	ActionKick   = "kick"
+	ActionBan    = "ban"
+	ActionUnban  = "unban"
+)
+
+// ModCase is a moderation action taken against a member
+type ModCase struct {
+	ID          int64
+	UserID      string
+	ModeratorID string
+	Action      string
+	Reason      string
+	Created     time.Time
+	Expires     *time.Time // When a mute or ban is automatically lifted, nil if it's permanent
+	Resolved    bool       // Whether a mute or ban has been lifted
+}
+
+// CreateCase records a moderation action, setting its case number
+func CreateCase(c *ModCase) error {
+	var expires interface{}
+	if c.Expires != nil {
+		expires = c.Expires.UTC()
+	}
+	c.Created = time.Now()
+	result, err := exec(
+		"INSERT INTO mod_cases(user_id, moderator_id, action, reason, created, expires, resolved) VALUES(?, ?, ?, ?, ?, ?, ?)",
+		c.UserID, c.ModeratorID, c.Action, c.Reason, c.Created.UTC(), expires, c.Resolved,
+	)
+	if err != nil {
+		return err
+	}
+	c.ID, err = result.LastInsertId()
+	return err
+}
+
+// UserCases returns every case against a user, most recent first
+func UserCases(userID string) ([]*ModCase, error) {
+	return queryCases("SELECT id, user_id, moderator_id, action, reason, created, expires, resolved FROM mod_cases WHERE user_id = ? ORDER BY id DESC", userID)
+}
+
+// ExpiredCases returns unresolved mutes and bans which expired before the given time
+func ExpiredCases(before time.Time) ([]*ModCase, error) {
+	return queryCases(
+		"SELECT id, user_id, moderator_id, action, reason, created, expires, resolved FROM mod_cases WHERE resolved = FALSE AND expires IS NOT NULL AND expires < ?",
+		before.UTC(),
+	)
+}
+
+// ResolveCases marks every unresolved case of the given action against a user as resolved
+func ResolveCases(userID, action string) error {
+	_, err := exec("UPDATE mod_cases SET resolved = TRUE WHERE user_id = ? AND action = ? AND resolved = FALSE", userID, action)
+	return err
+}
+
+func queryCases(q string, args ...interface{}) ([]*ModCase, error) {
+	rows, err := query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cases := []*ModCase{}
+	for rows.Next() {
+		c := &ModCase{}
+		var expires sql.NullTime
+		if err := rows.Scan(&c.ID, &c.UserID, &c.ModeratorID, &c.Action, &c.Reason, &c.Created, &expires, &c.Resolved); err != nil {
+			return nil, err
+		}
+		if expires.Valid {
+			c.Expires = &expires.Time
+		}
+		cases = append(cases, c)
+	}
+	return cases, rows.Err()
+}
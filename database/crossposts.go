@@ -0,0 +1,66 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// CrossPost records an entry which has been posted to an outlet such as twitter
+type CrossPost struct {
+	MessageID string
+	Outlet    string
+	PostIDs   []string
+	Posted    time.Time
+}
+
+// ClaimCrossPost marks the message as being posted to the outlet
+// It returns false if the message has already been claimed for that outlet
+func ClaimCrossPost(messageID, outlet string) (bool, error) {
+	result, err := exec(
+		sqlDialect.insertIgnore+" INTO cross_posts(message_id, outlet, post_ids, posted) VALUES(?, ?, '', ?)",
+		messageID, outlet, time.Now().UTC(),
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+// SetCrossPostIDs stores the ids of the posts made on the outlet
+func SetCrossPostIDs(messageID, outlet string, postIDs []string) error {
+	_, err := exec(
+		"UPDATE cross_posts SET post_ids = ? WHERE message_id = ? AND outlet = ?",
+		strings.Join(postIDs, ","), messageID, outlet,
+	)
+	return err
+}
+
+// GetCrossPost returns the cross post of the message to the outlet
+func GetCrossPost(messageID, outlet string) (*CrossPost, error) {
+	var (
+		postIDs string
+		c       = &CrossPost{MessageID: messageID, Outlet: outlet}
+	)
+	err := queryRow(
+		"SELECT post_ids, posted FROM cross_posts WHERE message_id = ? AND outlet = ?", messageID, outlet,
+	).Scan(&postIDs, &c.Posted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if postIDs != "" {
+		c.PostIDs = strings.Split(postIDs, ",")
+	}
+	return c, nil
+}
+
+// DeleteCrossPost releases the message so it can be posted to the outlet again
+func DeleteCrossPost(messageID, outlet string) error {
+	_, err := exec("DELETE FROM cross_posts WHERE message_id = ? AND outlet = ?", messageID, outlet)
+	return err
+}
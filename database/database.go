@@ -0,0 +1,250 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Strum355/log"
+	// Needed for mysql
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/spf13/viper"
+	// Needed for sqlite
+	_ "modernc.org/sqlite"
+)
+
+var (
+	db *sql.DB
+
+	// Statements are prepared the first time they're used and reused after
+	statements     = make(map[string]*sql.Stmt)
+	statementsLock sync.Mutex
+)
+
+// Connect opens the bot's database and checks its schema is up to date
+func Connect() error {
+	if err := Open(); err != nil {
+		return err
+	}
+	return checkSchema(viper.GetBool("database.auto_migrate"))
+}
+
+// Open the bot's database without checking its schema
+func Open() error {
+	switch driver := viper.GetString("database.driver"); driver {
+	case mysqlDialect.driver:
+		return openMySQL()
+	case sqliteDialect.driver:
+		return openSQLite(viper.GetString("sqlite.path"))
+	default:
+		return fmt.Errorf("unknown database driver %q, expected %q or %q", driver, mysqlDialect.driver, sqliteDialect.driver)
+	}
+}
+
+func openMySQL() error {
+	conn, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true", viper.GetString("mysql.username"), viper.GetString("mysql.password"), viper.GetString("mysql.url"), mysqlDBName()))
+	if err != nil {
+		return err
+	}
+	db = conn
+	sqlDialect = mysqlDialect
+	return db.Ping()
+}
+
+// mysqlDBName returns the name of the MySQL database
+// It used to be set with prom.dbname, which is still used if mysql.dbname isn't set
+func mysqlDBName() string {
+	if name := viper.GetString("mysql.dbname"); name != "" {
+		return name
+	}
+	if legacy := viper.GetString("prom.dbname"); legacy != "" {
+		log.Warn("prom.dbname is deprecated, set mysql.dbname instead")
+		return legacy
+	}
+	return "promexporter"
+}
+
+// openSQLite opens the database file at path, which is created if it doesn't exist
+// A path of ":memory:" opens a throwaway database which only lasts until it's closed
+func openSQLite(path string) error {
+	if path != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+	}
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	// SQLite only allows one writer at a time, so share one connection rather than failing with the database being locked.
+	// This also keeps an in memory database alive, as each connection would have its own
+	conn.SetMaxOpenConns(1)
+	conn.SetConnMaxLifetime(0)
+	db = conn
+	sqlDialect = sqliteDialect
+	_, err = db.Exec("PRAGMA busy_timeout = 5000")
+	return err
+}
+
+// Close the database connection
+func Close() {
+	closeStatements()
+	if db != nil {
+		db.Close()
+	}
+}
+
+// closeStatements closes every prepared statement, so they're prepared again on next use
+func closeStatements() {
+	statementsLock.Lock()
+	defer statementsLock.Unlock()
+	for query, stmt := range statements {
+		stmt.Close()
+		delete(statements, query)
+	}
+}
+
+// Ping checks the database can be reached
+func Ping(ctx context.Context) error {
+	if db == nil {
+		return errors.New("database isn't open")
+	}
+	return db.PingContext(ctx)
+}
+
+// timeout bounds how long a single query or transaction can take
+// SQLite isn't given one, as cancelling a context can interrupt the next statement on its shared connection.
+// Waiting for a lock is still bounded by its busy timeout
+func timeout() (context.Context, context.CancelFunc) {
+	if sqlDialect == sqliteDialect {
+		return context.Background(), func() {}
+	}
+	return context.WithTimeout(context.Background(), viper.GetDuration("database.timeout"))
+}
+
+func prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	statementsLock.Lock()
+	defer statementsLock.Unlock()
+	if stmt, ok := statements[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	statements[query] = stmt
+	return stmt, nil
+}
+
+func exec(query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := timeout()
+	defer cancel()
+	stmt, err := prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.ExecContext(ctx, args...)
+}
+
+// rows cancels its query's context once closed
+type rows struct {
+	*sql.Rows
+	cancel context.CancelFunc
+}
+
+func (r *rows) Close() error {
+	defer r.cancel()
+	return r.Rows.Close()
+}
+
+func query(query string, args ...interface{}) (*rows, error) {
+	ctx, cancel := timeout()
+	stmt, err := prepare(ctx, query)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	result, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &rows{Rows: result, cancel: cancel}, nil
+}
+
+// row cancels its query's context once scanned
+type row struct {
+	*sql.Row
+	err    error
+	cancel context.CancelFunc
+}
+
+func (r *row) Scan(dest ...interface{}) error {
+	defer r.cancel()
+	if r.err != nil {
+		return r.err
+	}
+	return r.Row.Scan(dest...)
+}
+
+func queryRow(query string, args ...interface{}) *row {
+	ctx, cancel := timeout()
+	stmt, err := prepare(ctx, query)
+	if err != nil {
+		return &row{err: err, cancel: cancel}
+	}
+	return &row{Row: stmt.QueryRowContext(ctx, args...), cancel: cancel}
+}
+
+// transaction runs fn in a transaction, committing if it succeeds and rolling back if it doesn't
+func transaction(fn func(tx *tx) error) error {
+	ctx, cancel := timeout()
+	defer cancel()
+	sqlTx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(&tx{Tx: sqlTx, ctx: ctx}); err != nil {
+		sqlTx.Rollback()
+		return err
+	}
+	return sqlTx.Commit()
+}
+
+// tx runs prepared statements within a transaction
+type tx struct {
+	*sql.Tx
+	ctx context.Context
+}
+
+// prepare returns the statement bound to the transaction
+// Statements are prepared on the transaction's connection, as the database may not have another free
+func (t *tx) prepare(query string) (*sql.Stmt, error) {
+	statementsLock.Lock()
+	stmt, ok := statements[query]
+	statementsLock.Unlock()
+	if ok {
+		return t.StmtContext(t.ctx, stmt), nil
+	}
+	return t.PrepareContext(t.ctx, query)
+}
+
+func (t *tx) exec(query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := t.prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.ExecContext(t.ctx, args...)
+}
+
+func (t *tx) queryRow(query string, args ...interface{}) *row {
+	stmt, err := t.prepare(query)
+	if err != nil {
+		return &row{err: err, cancel: func() {}}
+	}
+	return &row{Row: stmt.QueryRowContext(t.ctx, args...), cancel: func() {}}
+}
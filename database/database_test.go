@@ -0,0 +1,143 @@
+package database
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Strum355/log"
+	"github.com/spf13/viper"
+)
+
+func TestMain(m *testing.M) {
+	log.InitSimpleLogger(&log.Config{Output: ioutil.Discard})
+	viper.Set("database.timeout", "5s")
+	os.Exit(m.Run())
+}
+
+// openMemory opens a throwaway SQLite database at the latest schema version
+func openMemory(t *testing.T) {
+	t.Helper()
+	if err := openSQLite(":memory:"); err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(Close)
+	if err := checkSchema(true); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+}
+
+func TestMigrateDownAndUp(t *testing.T) {
+	openMemory(t)
+	created := tables(t)
+	useStats(t, 20)
+
+	if err := Migrate(0); err != nil {
+		t.Fatalf("failed to migrate down after use: %v", err)
+	}
+	if version, err := SchemaVersion(); err != nil || version != 0 {
+		t.Fatalf("expected version 0 after migrating down, got %d (%v)", version, err)
+	}
+	if left := tables(t); len(left) != 1 || left[0] != "schema_migrations" {
+		t.Fatalf("expected only schema_migrations after migrating down, got %v", left)
+	}
+
+	if err := Migrate(LatestVersion()); err != nil {
+		t.Fatalf("failed to migrate up after migrating down: %v", err)
+	}
+	if version, err := SchemaVersion(); err != nil || version != LatestVersion() {
+		t.Fatalf("expected version %d after migrating up, got %d (%v)", LatestVersion(), version, err)
+	}
+	if after := tables(t); fmt.Sprint(after) != fmt.Sprint(created) {
+		t.Fatalf("expected tables %v after migrating down and up, got %v", created, after)
+	}
+	useStats(t, 20)
+}
+
+// tables lists the tables in the database by name
+func tables(t *testing.T) []string {
+	t.Helper()
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		t.Fatalf("failed to list tables: %v", err)
+	}
+	defer rows.Close()
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("failed to list tables: %v", err)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestStats(t *testing.T) {
+	openMemory(t)
+	rounds := 500
+	useStats(t, rounds)
+
+	value, err := GetStat("test")
+	if err != nil || value != rounds {
+		t.Fatalf("expected stat to be %d, got %d (%v)", rounds, value, err)
+	}
+	counts, err := MessageCounts()
+	if err != nil {
+		t.Fatalf("failed to get message counts: %v", err)
+	}
+	if len(counts) != 1 || counts[0].Sent != 2*rounds || counts[0].Deleted != rounds {
+		t.Fatalf("unexpected message counts %+v", counts)
+	}
+	added, err := RecordJoins([]string{"1", "2", "2"})
+	if err != nil || added != 2 {
+		t.Fatalf("expected 2 joins to be added, got %d (%v)", added, err)
+	}
+	if added, err := RecordJoins([]string{"1", "3"}); err != nil || added != 1 {
+		t.Fatalf("expected 1 join to be added, got %d (%v)", added, err)
+	}
+	if joined, err := JoinedCount(); err != nil || joined != 3 {
+		t.Fatalf("expected 3 joined, got %d (%v)", joined, err)
+	}
+}
+
+// useStats runs the stats functions the way the bot does, failing on any error
+func useStats(t *testing.T, rounds int) {
+	t.Helper()
+	for i := 0; i < rounds; i++ {
+		if err := AddStat("test", 1); err != nil {
+			t.Fatalf("round %d: failed to add stat: %v", i, err)
+		}
+		if _, err := GetStat("test"); err != nil {
+			t.Fatalf("round %d: failed to get stat: %v", i, err)
+		}
+		if err := AddMessageCounts([]*MessageCount{{Server: "server", Channel: "channel", Sent: 2, Deleted: 1}}); err != nil {
+			t.Fatalf("round %d: failed to add message counts: %v", i, err)
+		}
+		if err := RecordMemberEvent(fmt.Sprint(i), MemberJoined); err != nil {
+			t.Fatalf("round %d: failed to record member event: %v", i, err)
+		}
+		if _, err := MemberEvents(time.Now().Add(-time.Hour), time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("round %d: failed to get member events: %v", i, err)
+		}
+	}
+}
+
+func TestRecordJoinsBatches(t *testing.T) {
+	openMemory(t)
+	ids := []string{}
+	for i := 0; i < 2*joinBatch+10; i++ {
+		ids = append(ids, fmt.Sprint(i))
+	}
+	if added, err := RecordJoins(ids[:joinBatch+5]); err != nil || added != joinBatch+5 {
+		t.Fatalf("expected %d joins to be added, got %d (%v)", joinBatch+5, added, err)
+	}
+	if added, err := RecordJoins(ids); err != nil || added != len(ids)-joinBatch-5 {
+		t.Fatalf("expected %d joins to be added, got %d (%v)", len(ids)-joinBatch-5, added, err)
+	}
+	if joined, err := JoinedCount(); err != nil || joined != len(ids) {
+		t.Fatalf("expected %d joined, got %d (%v)", len(ids), joined, err)
+	}
+}
@@ -0,0 +1,26 @@
+package database
+
+// dialect holds the SQL which differs between the supported databases
+type dialect struct {
+	driver string
+	// INSERT which skips rows that would conflict with an existing row
+	insertIgnore string
+	// Clause following an INSERT which updates the conflicting row instead, followed by the assignments
+	upsert string
+}
+
+var (
+	mysqlDialect = dialect{
+		driver:       "mysql",
+		insertIgnore: "INSERT IGNORE",
+		upsert:       "ON DUPLICATE KEY UPDATE",
+	}
+	sqliteDialect = dialect{
+		driver:       "sqlite",
+		insertIgnore: "INSERT OR IGNORE",
+		upsert:       "ON CONFLICT DO UPDATE SET",
+	}
+
+	// Dialect of the open database
+	sqlDialect = mysqlDialect
+)
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Statuses a draft moves through
+const (
+	DraftPending   = "pending"
+	DraftPublished = "published"
+	DraftRejected  = "rejected"
+	DraftExpired   = "expired"
+)
+
+// Draft is an event or announcement waiting on committee before it's published
+type Draft struct {
+	PreviewID string // Message previewing the draft, which committee react to
+	ChannelID string
+	CommandID string // Message containing the command which created the draft
+	AuthorID  string
+	Kind      string // Command used to create the draft
+	Required  int    // Approvals needed to publish
+	Status    string
+	Expires   time.Time
+}
+
+// SaveDraft stores a new pending draft
+func SaveDraft(d *Draft) error {
+	_, err := exec(
+		"INSERT INTO drafts(preview_id, channel_id, command_id, author_id, kind, required, status, expires) VALUES(?, ?, ?, ?, ?, ?, ?, ?)",
+		d.PreviewID, d.ChannelID, d.CommandID, d.AuthorID, d.Kind, d.Required, DraftPending, d.Expires.UTC(),
+	)
+	return err
+}
+
+// GetDraft returns the draft previewed by the given message
+func GetDraft(previewID string) (*Draft, error) {
+	return scanDraft(queryRow(
+		"SELECT preview_id, channel_id, command_id, author_id, kind, required, status, expires FROM drafts WHERE preview_id = ?", previewID,
+	))
+}
+
+// GetDraftByCommand returns the latest draft created by the given command message
+func GetDraftByCommand(commandID string) (*Draft, error) {
+	return scanDraft(queryRow(
+		"SELECT preview_id, channel_id, command_id, author_id, kind, required, status, expires FROM drafts WHERE command_id = ? ORDER BY expires DESC LIMIT 1", commandID,
+	))
+}
+
+// ExpiredDrafts returns pending drafts which expired before the given time
+func ExpiredDrafts(before time.Time) ([]*Draft, error) {
+	rows, err := query(
+		"SELECT preview_id, channel_id, command_id, author_id, kind, required, status, expires FROM drafts WHERE status = ? AND expires < ?", DraftPending, before.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	drafts := []*Draft{}
+	for rows.Next() {
+		d, err := scanDraft(rows)
+		if err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, d)
+	}
+	return drafts, rows.Err()
+}
+
+// FinishDraft moves a pending draft to the given status
+// It returns false if the draft was no longer pending, so it's only ever finished once
+func FinishDraft(previewID, status string) (bool, error) {
+	result, err := exec("UPDATE drafts SET status = ? WHERE preview_id = ? AND status = ?", status, previewID, DraftPending)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+// AddApproval records a user's approval of a draft, returning the number of distinct approvals
+func AddApproval(previewID, userID string) (int, error) {
+	_, err := exec(sqlDialect.insertIgnore+" INTO draft_approvals(preview_id, user_id) VALUES(?, ?)", previewID, userID)
+	if err != nil {
+		return 0, err
+	}
+	return countApprovals(previewID)
+}
+
+// RemoveApproval withdraws a user's approval of a draft, returning the number of distinct approvals
+func RemoveApproval(previewID, userID string) (int, error) {
+	_, err := exec("DELETE FROM draft_approvals WHERE preview_id = ? AND user_id = ?", previewID, userID)
+	if err != nil {
+		return 0, err
+	}
+	return countApprovals(previewID)
+}
+
+func countApprovals(previewID string) (int, error) {
+	var count int
+	err := queryRow("SELECT COUNT(*) FROM draft_approvals WHERE preview_id = ?", previewID).Scan(&count)
+	return count, err
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDraft(row scanner) (*Draft, error) {
+	d := &Draft{}
+	err := row.Scan(&d.PreviewID, &d.ChannelID, &d.CommandID, &d.AuthorID, &d.Kind, &d.Required, &d.Status, &d.Expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Expired reports whether the draft can no longer be published
+func (d *Draft) Expired() bool {
+	return time.Now().After(d.Expires)
+}
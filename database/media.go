@@ -0,0 +1,55 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Media is an attachment which has been copied into the media store
+type Media struct {
+	AttachmentID string
+	ID           string
+	Filename     string
+	ContentType  string
+	Size         int
+}
+
+// SaveMedia records that an attachment has been stored
+func SaveMedia(m *Media) error {
+	_, err := exec(
+		sqlDialect.insertIgnore+" INTO media(attachment_id, hash, filename, content_type, size, created) VALUES(?, ?, ?, ?, ?, ?)",
+		m.AttachmentID, m.ID, m.Filename, m.ContentType, m.Size, time.Now().UTC(),
+	)
+	return err
+}
+
+// GetMediaByAttachment returns the stored media for a discord attachment
+func GetMediaByAttachment(attachmentID string) (*Media, error) {
+	m := &Media{AttachmentID: attachmentID}
+	err := queryRow(
+		"SELECT hash, filename, content_type, size FROM media WHERE attachment_id = ?", attachmentID,
+	).Scan(&m.ID, &m.Filename, &m.ContentType, &m.Size)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GetMedia returns the stored media with the given content address
+func GetMedia(id string) (*Media, error) {
+	m := &Media{ID: id}
+	err := queryRow(
+		"SELECT attachment_id, filename, content_type, size FROM media WHERE hash = ? LIMIT 1", id,
+	).Scan(&m.AttachmentID, &m.Filename, &m.ContentType, &m.Size)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
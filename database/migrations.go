@@ -0,0 +1,300 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Strum355/log"
+)
+
+// migration changes the schema from the previous version to its version
+type migration struct {
+	version int
+	name    string
+	up      []string
+	down    []string
+	// Statements run instead of up on SQLite, if up uses MySQL only syntax
+	sqlite []string
+}
+
+// Every schema change is added as a new migration at the end. The statements of existing migrations must never be changed,
+// as databases they've already run on wouldn't be updated. Adding a variant for a newly supported driver is the only exception,
+// as no database using it can have run them yet
+// The first migrations create tables if they don't exist, as databases used to be set up without them
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "stats",
+		up: []string{
+			"CREATE TABLE IF NOT EXISTS stats(name VARCHAR(20) PRIMARY KEY, value INT)",
+			"CREATE TABLE IF NOT EXISTS messageCount(server VARCHAR(20), channel VARCHAR(20), value INT, PRIMARY KEY (server, channel))",
+			"CREATE TABLE IF NOT EXISTS joined(id VARCHAR(20))",
+			"CREATE TABLE IF NOT EXISTS schedule(game VARCHAR(32), opponent VARCHAR(8), time DATETIME)",
+		},
+		down: []string{
+			"DROP TABLE IF EXISTS schedule",
+			"DROP TABLE IF EXISTS joined",
+			"DROP TABLE IF EXISTS messageCount",
+			"DROP TABLE IF EXISTS stats",
+		},
+	},
+	{
+		version: 2,
+		name:    "cross posts",
+		up: []string{
+			"CREATE TABLE IF NOT EXISTS pending_posts(message_id VARCHAR(20) PRIMARY KEY, tweets TEXT, image_urls TEXT, expires DATETIME)",
+			"CREATE TABLE IF NOT EXISTS cross_posts(message_id VARCHAR(20), outlet VARCHAR(20), post_ids TEXT, posted DATETIME, PRIMARY KEY (message_id, outlet))",
+		},
+		down: []string{
+			"DROP TABLE IF EXISTS cross_posts",
+			"DROP TABLE IF EXISTS pending_posts",
+		},
+	},
+	{
+		version: 3,
+		name:    "media",
+		up: []string{
+			"CREATE TABLE IF NOT EXISTS media(attachment_id VARCHAR(20) PRIMARY KEY, hash CHAR(64), filename VARCHAR(255), content_type VARCHAR(64), size INT, created DATETIME, INDEX (hash))",
+		},
+		down: []string{
+			"DROP TABLE IF EXISTS media",
+		},
+		sqlite: []string{
+			"CREATE TABLE IF NOT EXISTS media(attachment_id VARCHAR(20) PRIMARY KEY, hash CHAR(64), filename VARCHAR(255), content_type VARCHAR(64), size INT, created DATETIME)",
+			"CREATE INDEX IF NOT EXISTS media_hash ON media(hash)",
+		},
+	},
+	{
+		version: 4,
+		name:    "drafts",
+		up: []string{
+			"CREATE TABLE IF NOT EXISTS drafts(preview_id VARCHAR(20) PRIMARY KEY, channel_id VARCHAR(20), command_id VARCHAR(20), author_id VARCHAR(20), kind VARCHAR(20), required INT, status VARCHAR(20), expires DATETIME, INDEX (command_id))",
+			"CREATE TABLE IF NOT EXISTS draft_approvals(preview_id VARCHAR(20), user_id VARCHAR(20), PRIMARY KEY (preview_id, user_id))",
+		},
+		down: []string{
+			"DROP TABLE IF EXISTS draft_approvals",
+			"DROP TABLE IF EXISTS drafts",
+		},
+		sqlite: []string{
+			"CREATE TABLE IF NOT EXISTS drafts(preview_id VARCHAR(20) PRIMARY KEY, channel_id VARCHAR(20), command_id VARCHAR(20), author_id VARCHAR(20), kind VARCHAR(20), required INT, status VARCHAR(20), expires DATETIME)",
+			"CREATE INDEX IF NOT EXISTS drafts_command_id ON drafts(command_id)",
+			"CREATE TABLE IF NOT EXISTS draft_approvals(preview_id VARCHAR(20), user_id VARCHAR(20), PRIMARY KEY (preview_id, user_id))",
+		},
+	},
+	{
+		version: 5,
+		name:    "role menus",
+		up: []string{
+			"CREATE TABLE IF NOT EXISTS role_menus(message_id VARCHAR(20), channel_id VARCHAR(20), emoji VARCHAR(100), role_id VARCHAR(20), PRIMARY KEY (message_id, emoji))",
+		},
+		down: []string{
+			"DROP TABLE IF EXISTS role_menus",
+		},
+	},
+	{
+		version: 6,
+		name:    "moderation cases",
+		up: []string{
+			"CREATE TABLE IF NOT EXISTS mod_cases(id INT AUTO_INCREMENT PRIMARY KEY, user_id VARCHAR(20), moderator_id VARCHAR(20), action VARCHAR(20), reason TEXT, created DATETIME, expires DATETIME NULL, resolved BOOLEAN, INDEX (user_id))",
+		},
+		down: []string{
+			"DROP TABLE IF EXISTS mod_cases",
+		},
+		sqlite: []string{
+			"CREATE TABLE IF NOT EXISTS mod_cases(id INTEGER PRIMARY KEY AUTOINCREMENT, user_id VARCHAR(20), moderator_id VARCHAR(20), action VARCHAR(20), reason TEXT, created DATETIME, expires DATETIME NULL, resolved BOOLEAN)",
+			"CREATE INDEX IF NOT EXISTS mod_cases_user_id ON mod_cases(user_id)",
+		},
+	},
+	{
+		version: 7,
+		name:    "message and event totals",
+		// Message counts used to go down when messages were deleted, they're now kept separately.
+		// The event count is kept as the number created for the same reason. It was the number created less those
+		// revoked, which can't be recovered, so eventsCreated starts out short by however many had been revoked
+		up: []string{
+			"ALTER TABLE messageCount ADD COLUMN deleted INT NOT NULL DEFAULT 0",
+			"UPDATE stats SET name = 'eventsCreated' WHERE name = 'eventCount'",
+		},
+		down: []string{
+			"UPDATE stats SET name = 'eventCount' WHERE name = 'eventsCreated'",
+			"DELETE FROM stats WHERE name = 'eventsRevoked'",
+			"ALTER TABLE messageCount DROP COLUMN deleted",
+		},
+	},
+	{
+		version: 8,
+		name:    "member events and message activity",
+		up: []string{
+			"CREATE TABLE IF NOT EXISTS member_events(id INT AUTO_INCREMENT PRIMARY KEY, user_id VARCHAR(20), kind VARCHAR(20), created DATETIME, INDEX (created))",
+			"CREATE TABLE IF NOT EXISTS message_activity(day CHAR(10), server VARCHAR(20), channel VARCHAR(20), messages INT, PRIMARY KEY (day, server, channel))",
+		},
+		down: []string{
+			"DROP TABLE IF EXISTS message_activity",
+			"DROP TABLE IF EXISTS member_events",
+		},
+		sqlite: []string{
+			"CREATE TABLE IF NOT EXISTS member_events(id INTEGER PRIMARY KEY AUTOINCREMENT, user_id VARCHAR(20), kind VARCHAR(20), created DATETIME)",
+			"CREATE INDEX IF NOT EXISTS member_events_created ON member_events(created)",
+			"CREATE TABLE IF NOT EXISTS message_activity(day CHAR(10), server VARCHAR(20), channel VARCHAR(20), messages INT, PRIMARY KEY (day, server, channel))",
+		},
+	},
+	{
+		version: 9,
+		name:    "user activity",
+		up: []string{
+			"CREATE TABLE IF NOT EXISTS user_activity(day CHAR(10), server VARCHAR(20), channel VARCHAR(20), user_id VARCHAR(20), messages INT, voice_seconds INT, PRIMARY KEY (day, server, channel, user_id), INDEX (user_id))",
+			"CREATE TABLE IF NOT EXISTS activity_preferences(user_id VARCHAR(20) PRIMARY KEY, tracked BOOLEAN)",
+		},
+		down: []string{
+			"DROP TABLE IF EXISTS activity_preferences",
+			"DROP TABLE IF EXISTS user_activity",
+		},
+		sqlite: []string{
+			"CREATE TABLE IF NOT EXISTS user_activity(day CHAR(10), server VARCHAR(20), channel VARCHAR(20), user_id VARCHAR(20), messages INT, voice_seconds INT, PRIMARY KEY (day, server, channel, user_id))",
+			"CREATE INDEX IF NOT EXISTS user_activity_user_id ON user_activity(user_id)",
+			"CREATE TABLE IF NOT EXISTS activity_preferences(user_id VARCHAR(20) PRIMARY KEY, tracked BOOLEAN)",
+		},
+	},
+	{
+		version: 10,
+		name:    "voice sessions",
+		up: []string{
+			"CREATE TABLE IF NOT EXISTS voice_sessions(id INT AUTO_INCREMENT PRIMARY KEY, server VARCHAR(20), channel VARCHAR(20), user_id VARCHAR(20), started DATETIME, ended DATETIME, seconds INT, INDEX (ended))",
+		},
+		down: []string{
+			"DROP TABLE IF EXISTS voice_sessions",
+		},
+		sqlite: []string{
+			"CREATE TABLE IF NOT EXISTS voice_sessions(id INTEGER PRIMARY KEY AUTOINCREMENT, server VARCHAR(20), channel VARCHAR(20), user_id VARCHAR(20), started DATETIME, ended DATETIME, seconds INT)",
+			"CREATE INDEX IF NOT EXISTS voice_sessions_ended ON voice_sessions(ended)",
+		},
+	},
+	{
+		version: 11,
+		name:    "site checks",
+		up: []string{
+			"CREATE TABLE IF NOT EXISTS site_checks(id INT AUTO_INCREMENT PRIMARY KEY, site VARCHAR(255), checked DATETIME, up BOOLEAN, latency INT, INDEX (checked))",
+		},
+		down: []string{
+			"DROP TABLE IF EXISTS site_checks",
+		},
+		sqlite: []string{
+			"CREATE TABLE IF NOT EXISTS site_checks(id INTEGER PRIMARY KEY AUTOINCREMENT, site VARCHAR(255), checked DATETIME, up BOOLEAN, latency INT)",
+			"CREATE INDEX IF NOT EXISTS site_checks_checked ON site_checks(checked)",
+		},
+	},
+	{
+		version: 12,
+		name:    "unique joins",
+		// The table is copied, dropping any duplicates, as a primary key can't be added to it while they exist
+		up: []string{
+			"CREATE TABLE joined_unique(id VARCHAR(20) PRIMARY KEY)",
+			"INSERT INTO joined_unique(id) SELECT DISTINCT id FROM joined WHERE id IS NOT NULL",
+			"DROP TABLE joined",
+			"ALTER TABLE joined_unique RENAME TO joined",
+		},
+		down: []string{
+			"CREATE TABLE joined_duplicates(id VARCHAR(20))",
+			"INSERT INTO joined_duplicates(id) SELECT id FROM joined",
+			"DROP TABLE joined",
+			"ALTER TABLE joined_duplicates RENAME TO joined",
+		},
+	},
+}
+
+// LatestVersion is the schema version this build of the bot uses
+func LatestVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// SchemaVersion returns the version the database has been migrated to, 0 if it hasn't been
+func SchemaVersion() (int, error) {
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS schema_migrations(version INT PRIMARY KEY, name VARCHAR(100), applied DATETIME)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create table schema_migrations: %w", err)
+	}
+	var version sql.NullInt64
+	if err := db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// Migrate applies or reverts migrations until the database is at the target version
+func Migrate(target int) error {
+	if target < 0 || target > LatestVersion() {
+		return fmt.Errorf("unknown schema version %d, the latest is %d", target, LatestVersion())
+	}
+	current, err := SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if current > LatestVersion() {
+		return fmt.Errorf("database schema version %d is newer than the latest known version %d", current, LatestVersion())
+	}
+	// Statements prepared against the old schema may refer to tables which have changed
+	defer closeStatements()
+	for _, m := range migrations {
+		if m.version <= current || m.version > target {
+			continue
+		}
+		log.WithFields(log.Fields{"version": m.version, "name": m.name}).Info("Applying migration")
+		if err := runSteps(m.upSteps()); err != nil {
+			return fmt.Errorf("failed to apply migration %d (%s): %w", m.version, m.name, err)
+		}
+		_, err := db.Exec("INSERT INTO schema_migrations(version, name, applied) VALUES(?, ?, ?)", m.version, m.name, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.version > current || m.version <= target {
+			continue
+		}
+		log.WithFields(log.Fields{"version": m.version, "name": m.name}).Info("Reverting migration")
+		if err := runSteps(m.down); err != nil {
+			return fmt.Errorf("failed to revert migration %d (%s): %w", m.version, m.name, err)
+		}
+		if _, err := db.Exec("DELETE FROM schema_migrations WHERE version = ?", m.version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSchema migrates an older database to the latest version and refuses to use a newer one,
+// as it may have been changed in ways this build doesn't understand
+func checkSchema(autoMigrate bool) error {
+	current, err := SchemaVersion()
+	if err != nil {
+		return err
+	}
+	switch {
+	case current > LatestVersion():
+		return fmt.Errorf("database schema version %d is newer than the latest known version %d, update the bot or migrate the database down", current, LatestVersion())
+	case current < LatestVersion() && !autoMigrate:
+		return fmt.Errorf("database schema version %d is out of date, run the bot with -migrate to update it to version %d", current, LatestVersion())
+	case current < LatestVersion():
+		return Migrate(LatestVersion())
+	}
+	return nil
+}
+
+// upSteps returns the statements applying the migration to the open database
+func (m migration) upSteps() []string {
+	if sqlDialect == sqliteDialect && m.sqlite != nil {
+		return m.sqlite
+	}
+	return m.up
+}
+
+func runSteps(steps []string) error {
+	for _, step := range steps {
+		if _, err := db.Exec(step); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when a row doesn't exist
+var ErrNotFound = errors.New("not found")
+
+// PendingPost is a cross-post waiting on confirmation from committee
+type PendingPost struct {
+	MessageID string
+	Tweets    []string
+	ImageURLs []string
+	Expires   time.Time
+}
+
+// Expired reports whether the post can no longer be confirmed
+func (p *PendingPost) Expired() bool {
+	return time.Now().After(p.Expires)
+}
+
+// SavePendingPost stores a pending post, replacing any with the same message id
+func SavePendingPost(p *PendingPost) error {
+	tweets, err := json.Marshal(p.Tweets)
+	if err != nil {
+		return err
+	}
+	imageURLs, err := json.Marshal(p.ImageURLs)
+	if err != nil {
+		return err
+	}
+	_, err = exec(
+		"REPLACE INTO pending_posts(message_id, tweets, image_urls, expires) VALUES(?, ?, ?, ?)",
+		p.MessageID, string(tweets), string(imageURLs), p.Expires.UTC(),
+	)
+	return err
+}
+
+// GetPendingPost returns the pending post for the given message id
+func GetPendingPost(messageID string) (*PendingPost, error) {
+	var (
+		tweets    string
+		imageURLs string
+		p         = &PendingPost{MessageID: messageID}
+	)
+	err := queryRow(
+		"SELECT tweets, image_urls, expires FROM pending_posts WHERE message_id = ?", messageID,
+	).Scan(&tweets, &imageURLs, &p.Expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(tweets), &p.Tweets); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(imageURLs), &p.ImageURLs); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// DeletePendingPost removes the pending post for the given message id
+func DeletePendingPost(messageID string) error {
+	_, err := exec("DELETE FROM pending_posts WHERE message_id = ?", messageID)
+	return err
+}
+
+// PurgePendingPosts removes pending posts which expired before the given time
+func PurgePendingPosts(before time.Time) (int64, error) {
+	result, err := exec("DELETE FROM pending_posts WHERE expires < ?", before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
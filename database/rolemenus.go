@@ -0,0 +1,49 @@
+package database
+
+// RoleMenuOption maps a reaction on a role menu message to the role it gives
+type RoleMenuOption struct {
+	MessageID string
+	ChannelID string
+	Emoji     string // Emoji in the format used by the discord api
+	RoleID    string
+}
+
+// SaveRoleMenu stores the options of a role menu
+func SaveRoleMenu(options []*RoleMenuOption) error {
+	return transaction(func(tx *tx) error {
+		for _, o := range options {
+			_, err := tx.exec(
+				"REPLACE INTO role_menus(message_id, channel_id, emoji, role_id) VALUES(?, ?, ?, ?)",
+				o.MessageID, o.ChannelID, o.Emoji, o.RoleID,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RoleMenus returns the options of every role menu
+func RoleMenus() ([]*RoleMenuOption, error) {
+	rows, err := query("SELECT message_id, channel_id, emoji, role_id FROM role_menus")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	options := []*RoleMenuOption{}
+	for rows.Next() {
+		o := &RoleMenuOption{}
+		if err := rows.Scan(&o.MessageID, &o.ChannelID, &o.Emoji, &o.RoleID); err != nil {
+			return nil, err
+		}
+		options = append(options, o)
+	}
+	return options, rows.Err()
+}
+
+// DeleteRoleMenu removes every option of a role menu
+func DeleteRoleMenu(messageID string) error {
+	_, err := exec("DELETE FROM role_menus WHERE message_id = ?", messageID)
+	return err
+}
@@ -0,0 +1,46 @@
+package database
+
+import "time"
+
+// SiteUptime is how many times a site was checked over a period and how many of those it was up
+type SiteUptime struct {
+	Site   string
+	Checks int
+	Up     int
+}
+
+// RecordSiteCheck stores whether a site was up when it was checked and how long it took to respond
+func RecordSiteCheck(site string, up bool, latency time.Duration, checked time.Time) error {
+	_, err := exec(
+		"INSERT INTO site_checks(site, checked, up, latency) VALUES(?, ?, ?, ?)",
+		site, checked.UTC(), up, latency.Milliseconds(),
+	)
+	return err
+}
+
+// SiteUptimes returns the checks of each site between from and to
+func SiteUptimes(from, to time.Time) ([]*SiteUptime, error) {
+	rows, err := query(
+		"SELECT site, COUNT(*), SUM(CASE WHEN up THEN 1 ELSE 0 END) FROM site_checks WHERE checked >= ? AND checked < ? GROUP BY site ORDER BY site",
+		from.UTC(), to.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	uptimes := []*SiteUptime{}
+	for rows.Next() {
+		u := &SiteUptime{}
+		if err := rows.Scan(&u.Site, &u.Checks, &u.Up); err != nil {
+			return nil, err
+		}
+		uptimes = append(uptimes, u)
+	}
+	return uptimes, rows.Err()
+}
+
+// DeleteSiteChecks removes the checks made before a time
+func DeleteSiteChecks(before time.Time) error {
+	_, err := exec("DELETE FROM site_checks WHERE checked < ?", before.UTC())
+	return err
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// MessageCount is the number of messages sent and deleted in a channel
+type MessageCount struct {
+	Server  string
+	Channel string
+	Sent    int
+	Deleted int
+}
+
+// joinBatch is how many members are recorded by a single statement, keeping within the databases' limits on parameters
+const joinBatch = 500
+
+// RecordJoins remembers members as having joined, returning how many hadn't before
+func RecordJoins(ids []string) (int, error) {
+	added := 0
+	for len(ids) > 0 {
+		batch := ids
+		if len(batch) > joinBatch {
+			batch = batch[:joinBatch]
+		}
+		ids = ids[len(batch):]
+
+		args := make([]interface{}, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+		result, err := exec(sqlDialect.insertIgnore+" INTO joined(id) VALUES (?)"+strings.Repeat(", (?)", len(batch)-1), args...)
+		if err != nil {
+			return added, err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return added, err
+		}
+		added += int(rows)
+	}
+	return added, nil
+}
+
+// JoinedCount returns how many members have ever joined
+func JoinedCount() (int, error) {
+	var count int
+	err := queryRow("SELECT COUNT(*) FROM joined").Scan(&count)
+	return count, err
+}
+
+// AddStat adds delta to a named stat, creating it if needed
+func AddStat(name string, delta int) error {
+	_, err := exec("INSERT INTO stats(name, value) VALUES(?, ?) "+sqlDialect.upsert+" value = value + ?", name, delta, delta)
+	return err
+}
+
+// GetStat returns the value of a named stat, which is 0 if it hasn't been set
+func GetStat(name string) (int, error) {
+	var value int
+	err := queryRow("SELECT value FROM stats WHERE name = ?", name).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return value, err
+}
+
+// AddMessageCounts adds to the number of messages sent and deleted in each of the channels,
+// also counting the messages sent towards today's activity
+func AddMessageCounts(counts []*MessageCount) error {
+	day := dayOf(time.Now())
+	return transaction(func(tx *tx) error {
+		for _, c := range counts {
+			_, err := tx.exec(
+				"INSERT INTO messageCount(server, channel, value, deleted) VALUES(?, ?, ?, ?) "+sqlDialect.upsert+" value = value + ?, deleted = deleted + ?",
+				c.Server, c.Channel, c.Sent, c.Deleted, c.Sent, c.Deleted,
+			)
+			if err != nil {
+				return err
+			}
+			if c.Sent == 0 {
+				continue
+			}
+			_, err = tx.exec(
+				"INSERT INTO message_activity(day, server, channel, messages) VALUES(?, ?, ?, ?) "+sqlDialect.upsert+" messages = messages + ?",
+				day, c.Server, c.Channel, c.Sent, c.Sent,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MessageCounts returns the number of messages sent and deleted in each channel
+func MessageCounts() ([]*MessageCount, error) {
+	rows, err := query("SELECT server, channel, value, deleted FROM messageCount")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := []*MessageCount{}
+	for rows.Next() {
+		c := &MessageCount{}
+		if err := rows.Scan(&c.Server, &c.Channel, &c.Sent, &c.Deleted); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// InsertScheduleItem stores a match a captain has scheduled
+func InsertScheduleItem(game, opponent string, at time.Time) error {
+	_, err := exec("INSERT INTO schedule(game, opponent, time) VALUES(?, ?, ?)", game, opponent, at.UTC())
+	return err
+}
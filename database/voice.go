@@ -0,0 +1,60 @@
+package database
+
+import "time"
+
+// VoiceSession is the time a user spent in a voice channel without leaving it
+// UserID is empty for users whose activity isn't tracked
+type VoiceSession struct {
+	Server  string
+	Channel string
+	UserID  string
+	Started time.Time
+	Ended   time.Time
+}
+
+// VoiceUsage is how much a voice channel was used over a period
+type VoiceUsage struct {
+	Server   string
+	Channel  string
+	Seconds  int
+	Sessions int
+}
+
+// RecordVoiceSession stores a finished voice session
+func RecordVoiceSession(v *VoiceSession) error {
+	_, err := exec(
+		"INSERT INTO voice_sessions(server, channel, user_id, started, ended, seconds) VALUES(?, ?, ?, ?, ?, ?)",
+		v.Server, v.Channel, v.UserID, v.Started.UTC(), v.Ended.UTC(), int(v.Ended.Sub(v.Started).Seconds()),
+	)
+	return err
+}
+
+// VoiceUsages returns how long each voice channel was used by sessions ending between from and to, most used first
+func VoiceUsages(from, to time.Time) ([]*VoiceUsage, error) {
+	return voiceUsages(
+		"SELECT server, channel, SUM(seconds) AS total, COUNT(*) FROM voice_sessions WHERE ended >= ? AND ended < ? GROUP BY server, channel ORDER BY total DESC",
+		from.UTC(), to.UTC(),
+	)
+}
+
+// VoiceTotals returns how long each voice channel has ever been used
+func VoiceTotals() ([]*VoiceUsage, error) {
+	return voiceUsages("SELECT server, channel, SUM(seconds), COUNT(*) FROM voice_sessions GROUP BY server, channel")
+}
+
+func voiceUsages(q string, args ...interface{}) ([]*VoiceUsage, error) {
+	rows, err := query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	usages := []*VoiceUsage{}
+	for rows.Next() {
+		u := &VoiceUsage{}
+		if err := rows.Scan(&u.Server, &u.Channel, &u.Seconds, &u.Sessions); err != nil {
+			return nil, err
+		}
+		usages = append(usages, u)
+	}
+	return usages, rows.Err()
+}
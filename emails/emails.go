@@ -1,6 +1,9 @@
 package emails
 
 import (
+	"fmt"
+
+	"github.com/UCCNetsoc/discord-bot/prometheus"
 	"github.com/sendgrid/rest"
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
@@ -15,5 +18,10 @@ func SendEmail(fromName, from, toName, to, subject, content, htmlContent string)
 	message.SetReplyTo(fromAddress)
 	client := sendgrid.NewSendClient(viper.GetString("sendgrid.token"))
 	response, err := client.Send(message)
+	if err == nil && response.StatusCode >= 400 {
+		prometheus.EmailSent(fmt.Errorf("sendgrid responded with status %d", response.StatusCode))
+	} else {
+		prometheus.EmailSent(err)
+	}
 	return response, err
 }
@@ -0,0 +1,195 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	// Needed to decode gifs
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Limits for images uploaded to twitter
+const (
+	TwitterMaxBytes     = 5 * 1024 * 1024
+	TwitterMaxDimension = 4096
+)
+
+// Names of the generated variants of an image
+const (
+	Original  = "original"
+	Web       = "web"
+	Thumbnail = "thumbnail"
+	Twitter   = "twitter"
+)
+
+// DetectType returns the MIME type of the image data
+func DetectType(data []byte) string {
+	return strings.Split(http.DetectContentType(data), ";")[0]
+}
+
+// Validate checks the image is of an allowed type and size
+func Validate(contentType string, size int) error {
+	allowed := false
+	for _, t := range viper.GetStringSlice("images.types") {
+		if t == contentType {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("Images of type %s aren't supported, please use one of %s", contentType, strings.Join(viper.GetStringSlice("images.types"), ", "))
+	}
+	if max := viper.GetInt("images.max_size"); size > max {
+		return fmt.Errorf("Image is too large (%dKB), the limit is %dKB", size/1024, max/1024)
+	}
+	return nil
+}
+
+// Variant generates the named variant of the image data
+// It returns the new image data and its MIME type
+func Variant(name string, data []byte) ([]byte, string, error) {
+	switch name {
+	case Web:
+		return Resize(data, viper.GetInt("images.web_width"))
+	case Thumbnail:
+		return Resize(data, viper.GetInt("images.thumbnail_width"))
+	case Twitter:
+		return ForTwitter(data)
+	case Original:
+		return data, DetectType(data), nil
+	}
+	return nil, "", fmt.Errorf("unknown image variant %s", name)
+}
+
+// Resize scales the image down to at most width pixels wide, keeping its aspect ratio
+// Images already narrow enough are returned unchanged. Resized gifs lose their animation
+// and are encoded as png
+func Resize(data []byte, width int) ([]byte, string, error) {
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	if src.Bounds().Dx() <= width {
+		return data, DetectType(data), nil
+	}
+	return encode(scale(src, width), format, 85)
+}
+
+// ForTwitter returns the image within twitter's size and dimension limits
+func ForTwitter(data []byte) ([]byte, string, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	if len(data) <= TwitterMaxBytes && config.Width <= TwitterMaxDimension && config.Height <= TwitterMaxDimension {
+		return data, DetectType(data), nil
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	width := src.Bounds().Dx()
+	if height := src.Bounds().Dy(); height > width {
+		// Scale by the longest side
+		width = width * TwitterMaxDimension / height
+	} else if width > TwitterMaxDimension {
+		width = TwitterMaxDimension
+	}
+	// Jpeg keeps posters well under the limit, but images with transparency stay png so it isn't lost
+	format := "jpeg"
+	if hasAlpha(src) {
+		format = "png"
+	}
+	// Shrink until it fits, lowering the quality down to 50 along the way
+	for quality := 90; ; {
+		resized, contentType, err := encode(scale(src, width), format, quality)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(resized) <= TwitterMaxBytes {
+			return resized, contentType, nil
+		}
+		if width == 1 {
+			return nil, "", fmt.Errorf("image can't be shrunk under twitter's %dMB limit", TwitterMaxBytes/1024/1024)
+		}
+		if quality > 50 {
+			quality -= 10
+		}
+		if width = width * 3 / 4; width < 1 {
+			width = 1
+		}
+	}
+}
+
+// hasAlpha reports whether the image may have transparent pixels
+func hasAlpha(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return !o.Opaque()
+	}
+	return true
+}
+
+func encode(img image.Image, format string, quality int) ([]byte, string, error) {
+	b := bytes.NewBuffer([]byte{})
+	switch format {
+	case "jpeg":
+		if err := jpeg.Encode(b, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, "", err
+		}
+		return b.Bytes(), "image/jpeg", nil
+	default:
+		if err := png.Encode(b, img); err != nil {
+			return nil, "", err
+		}
+		return b.Bytes(), "image/png", nil
+	}
+}
+
+// scale the image to the given width by averaging the pixels each new pixel covers
+func scale(src image.Image, width int) image.Image {
+	bounds := src.Bounds()
+	if width >= bounds.Dx() {
+		return src
+	}
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := bounds.Min.Y + (y+1)*bounds.Dy()/height
+		if y1 == y0 {
+			y1++
+		}
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := bounds.Min.X + (x+1)*bounds.Dx()/width
+			if x1 == x0 {
+				x1++
+			}
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
@@ -1,16 +1,24 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/UCCNetsoc/discord-bot/commands"
 
+	"github.com/UCCNetsoc/discord-bot/activity"
 	"github.com/UCCNetsoc/discord-bot/api"
+	"github.com/UCCNetsoc/discord-bot/database"
 	"github.com/UCCNetsoc/discord-bot/prometheus"
+	"github.com/UCCNetsoc/discord-bot/server"
 	"github.com/UCCNetsoc/discord-bot/status"
+	"github.com/UCCNetsoc/discord-bot/voice"
 
 	"github.com/Strum355/log"
 	"github.com/UCCNetsoc/discord-bot/config"
@@ -18,11 +26,17 @@ import (
 	"github.com/spf13/viper"
 )
 
-var production *bool
+var (
+	production *bool
+	migrate    *bool
+	migrateTo  *int
+)
 
 func main() {
 	// Check for flags
 	production = flag.Bool("p", false, "enables production with json logging")
+	migrate = flag.Bool("migrate", false, "migrates the database schema then exits")
+	migrateTo = flag.Int("migrate-to", -1, "schema version to migrate to with -migrate, defaults to the latest")
 	flag.Parse()
 	if *production {
 		log.InitJSONLogger(&log.Config{Output: os.Stdout})
@@ -33,19 +47,40 @@ func main() {
 	// Setup viper and consul
 	exitError(config.InitConfig())
 
+	if *migrate {
+		exitError(database.Open())
+		defer database.Close()
+		target := *migrateTo
+		if target < 0 {
+			target = database.LatestVersion()
+		}
+		exitError(database.Migrate(target))
+		log.WithFields(log.Fields{"version": target}).Info("Database migrated")
+		return
+	}
+
+	// Database connection
+	exitError(database.Connect())
+	defer database.Close()
+
 	// Discord connection
 	token := viper.GetString("discord.token")
 	session, err := discordgo.New("Bot " + token)
 	session.Identify.Intents = discordgo.MakeIntent(discordgo.IntentsAll)
 	exitError(err)
+	// The exporter tracks the gateway connection and loads the stored totals before anything can be counted,
+	// so it's created before connecting
+	prometheus.CreateExporter(session)
 	// Open websocket
 	err = session.Open()
 	commands.Register(session)
 	exitError(err)
 
-	// Run the REST API for events/announcements in a different goroutine
-	go api.Run(session)
-	go prometheus.CreateExporter(session)
+	// Serve the REST API for events/announcements, the prometheus exporter and health checks
+	api.Run(session)
+	server.AddCheck("database", database.Ping)
+	server.AddCheck("discord", discordCheck(session))
+	exitError(server.Start())
 
 	// Update the bot status periodically
 	go status.Status(session)
@@ -56,7 +91,30 @@ func main() {
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt, os.Kill)
 	<-sc
 	log.Info("Cleanly exiting")
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("server.shutdown_timeout"))
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.WithError(err).Error("Failed to shut down HTTP server")
+	}
 	session.Close()
+	voice.EndAll()
+	prometheus.Flush()
+	activity.Flush()
+}
+
+// discordCheck fails if the session isn't connected to the gateway or has stopped receiving heartbeat acknowledgements
+func discordCheck(s *discordgo.Session) server.Check {
+	return func(ctx context.Context) error {
+		s.RLock()
+		defer s.RUnlock()
+		if !s.DataReady {
+			return errors.New("not connected to the Discord gateway")
+		}
+		if since := time.Since(s.LastHeartbeatAck); since > 2*time.Minute {
+			return fmt.Errorf("no heartbeat acknowledged for %s", since.Round(time.Second))
+		}
+		return nil
+	}
 }
 
 func exitError(err error) {
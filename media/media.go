@@ -0,0 +1,111 @@
+package media
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/UCCNetsoc/discord-bot/images"
+	"github.com/spf13/viper"
+)
+
+// Path the api serves media under
+const Path = "/media/"
+
+var idRegex = regexp.MustCompile("^[0-9a-f]{64}$")
+
+// Store writes data to the media directory, returning its content address
+// Storing the same data twice is a no-op
+func Store(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	id := hex.EncodeToString(sum[:])
+	if err := write(filepath.Join(viper.GetString("media.dir"), id), data); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Load the data stored under id
+func Load(id string) ([]byte, error) {
+	if !ValidID(id) {
+		return nil, fmt.Errorf("invalid media id %q", id)
+	}
+	return ioutil.ReadFile(filepath.Join(viper.GetString("media.dir"), id))
+}
+
+// LoadVariant loads the named variant of the data stored under id, generating it on first use
+func LoadVariant(id, variant string) ([]byte, error) {
+	if variant == "" || variant == images.Original {
+		return Load(id)
+	}
+	if !ValidID(id) {
+		return nil, fmt.Errorf("invalid media id %q", id)
+	}
+	path := filepath.Join(viper.GetString("media.dir"), id+"-"+variant)
+	if data, err := ioutil.ReadFile(path); err == nil {
+		return data, nil
+	}
+	original, err := Load(id)
+	if err != nil {
+		return nil, err
+	}
+	data, _, err := images.Variant(variant, original)
+	if err != nil {
+		return nil, err
+	}
+	return data, write(path, data)
+}
+
+// ValidID checks id is a content address, so it's safe to use as a filename
+func ValidID(id string) bool {
+	return idRegex.MatchString(id)
+}
+
+// Served reports whether the media can be linked to, which needs the api's public url
+// Relative urls would break on the website, which is hosted elsewhere
+func Served() bool {
+	return viper.GetString("api.public_url") != ""
+}
+
+// URL the api serves the media at
+func URL(id string) string {
+	return strings.TrimSuffix(viper.GetString("api.public_url"), "/") + Path + id
+}
+
+// IDFromURL returns the media id if the url is one served by the api
+func IDFromURL(url string) (string, bool) {
+	prefix := strings.TrimSuffix(viper.GetString("api.public_url"), "/") + Path
+	if !strings.HasPrefix(url, prefix) {
+		return "", false
+	}
+	id := strings.TrimPrefix(url, prefix)
+	return id, ValidID(id)
+}
+
+// write data to path atomically, skipping files which already exist
+func write(path string, data []byte) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
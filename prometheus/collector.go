@@ -0,0 +1,121 @@
+package prometheus
+
+import (
+	"strings"
+
+	"github.com/UCCNetsoc/discord-bot/config"
+	"github.com/bwmarrin/discordgo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+)
+
+var (
+	memberCountDesc = prometheus.NewDesc(
+		"member_count",
+		"The number of registered members in the server",
+		nil, nil,
+	)
+	guildMembersDesc = prometheus.NewDesc(
+		"guild_members",
+		"The number of users in the server, registered or not",
+		nil, nil,
+	)
+	roleMembersDesc = prometheus.NewDesc(
+		"role_members",
+		"The number of members with each role",
+		// Role names aren't unique, so the id is needed to tell them apart
+		[]string{"role_id", "role"}, nil,
+	)
+	voiceMembersDesc = prometheus.NewDesc(
+		"voice_members",
+		"The number of members in each voice channel",
+		[]string{"channel"}, nil,
+	)
+)
+
+// guildCollector counts the members of the public server from the session state whenever metrics are scraped
+type guildCollector struct {
+	session *discordgo.Session
+}
+
+// Describe implements prometheus.Collector
+func (c *guildCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- memberCountDesc
+	ch <- guildMembersDesc
+	ch <- roleMembersDesc
+	ch <- voiceMembersDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *guildCollector) Collect(ch chan<- prometheus.Metric) {
+	servers := viper.Get("discord.servers").(*config.Servers)
+	guild, err := c.session.State.Guild(servers.PublicServer)
+	if err != nil {
+		return
+	}
+	c.session.State.RLock()
+	defer c.session.State.RUnlock()
+
+	registered := registrationRoles()
+	roleCounts := make(map[string]int)
+	members := 0
+	for _, member := range guild.Members {
+		isRegistered := false
+		for _, role := range member.Roles {
+			roleCounts[role]++
+			if registered[role] {
+				isRegistered = true
+			}
+		}
+		if isRegistered {
+			members++
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(memberCountDesc, prometheus.GaugeValue, float64(members))
+	ch <- prometheus.MustNewConstMetric(guildMembersDesc, prometheus.GaugeValue, float64(guild.MemberCount))
+	for _, role := range guild.Roles {
+		ch <- prometheus.MustNewConstMetric(roleMembersDesc, prometheus.GaugeValue, float64(roleCounts[role.ID]), role.ID, role.Name)
+	}
+	voiceCounts := make(map[string]int)
+	for _, state := range guild.VoiceStates {
+		voiceCounts[state.ChannelID]++
+	}
+	for _, channel := range guild.Channels {
+		if channel.Type == discordgo.ChannelTypeGuildVoice {
+			ch <- prometheus.MustNewConstMetric(voiceMembersDesc, prometheus.GaugeValue, float64(voiceCounts[channel.ID]), channel.ID)
+		}
+	}
+}
+
+// registrationRoles returns the set of roles given on completing registration
+func registrationRoles() map[string]bool {
+	roles := make(map[string]bool)
+	for _, roleID := range strings.Split(viper.GetString("discord.roles"), ",") {
+		if roleID != "" {
+			roles[roleID] = true
+		}
+	}
+	return roles
+}
+
+// registeredMembers returns the ids of members of the public server with one of the roles given on registration
+func registeredMembers(s *discordgo.Session) []string {
+	servers := viper.Get("discord.servers").(*config.Servers)
+	guild, err := s.State.Guild(servers.PublicServer)
+	if err != nil {
+		return nil
+	}
+	s.State.RLock()
+	defer s.State.RUnlock()
+	registered := registrationRoles()
+	ids := []string{}
+	for _, member := range guild.Members {
+		for _, role := range member.Roles {
+			if registered[role] {
+				ids = append(ids, member.User.ID)
+				break
+			}
+		}
+	}
+	return ids
+}
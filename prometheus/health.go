@@ -0,0 +1,151 @@
+package prometheus
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Outcomes of a command invocation
+const (
+	CommandOK      = "ok"
+	CommandPanic   = "panic"
+	CommandUnknown = "unknown"
+)
+
+// Results of publishing to an outlet
+const (
+	PublishSuccess = "success"
+	PublishPartial = "partial"
+	PublishFailure = "failure"
+)
+
+var (
+	commandsInvoked = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_commands_total",
+		Help: "The number of commands invoked, by command and outcome",
+	},
+		[]string{
+			"command",
+			"outcome",
+		})
+	commandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bot_command_duration_seconds",
+		Help:    "How long commands took to run",
+		Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	},
+		[]string{
+			"command",
+		})
+	gatewayEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_gateway_events_total",
+		Help: "The number of times the bot connected, disconnected or resumed its session with the Discord gateway",
+	},
+		[]string{
+			"event",
+		})
+	apiRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_api_requests_total",
+		Help: "The number of requests to the REST API, by route, method and status code",
+	},
+		[]string{
+			"route",
+			"method",
+			"code",
+		})
+	apiDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bot_api_request_duration_seconds",
+		Help:    "How long requests to the REST API took",
+		Buckets: prometheus.DefBuckets,
+	},
+		[]string{
+			"route",
+			"method",
+		})
+	emailsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_emails_total",
+		Help: "The number of emails sent, by whether they succeeded",
+	},
+		[]string{
+			"result",
+		})
+	outletPublishes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_outlet_publishes_total",
+		Help: "The number of entries published to outlets such as twitter, by result",
+	},
+		[]string{
+			"outlet",
+			"result",
+		})
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_cache_lookups_total",
+		Help: "The number of lookups of cached values, by whether the value was cached",
+	},
+		[]string{
+			"cache",
+			"result",
+		})
+)
+
+// CommandInvoked is called after a command has run
+func CommandInvoked(command, outcome string, duration time.Duration) {
+	commandsInvoked.WithLabelValues(command, outcome).Inc()
+	if outcome != CommandUnknown {
+		commandDuration.WithLabelValues(command).Observe(duration.Seconds())
+	}
+}
+
+// EmailSent is called after trying to send an email
+func EmailSent(err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	emailsSent.WithLabelValues(result).Inc()
+}
+
+// OutletPublish is called after trying to publish an entry to an outlet
+func OutletPublish(outlet, result string) {
+	outletPublishes.WithLabelValues(outlet, result).Inc()
+}
+
+// CacheLookup is called whenever a cached value is looked up
+func CacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookups.WithLabelValues(cache, result).Inc()
+}
+
+// InstrumentHandler counts and times the requests to a REST API route
+func InstrumentHandler(route string, handler http.HandlerFunc) http.HandlerFunc {
+	labels := prometheus.Labels{"route": route}
+	return promhttp.InstrumentHandlerCounter(
+		apiRequests.MustCurryWith(labels),
+		promhttp.InstrumentHandlerDuration(apiDuration.MustCurryWith(labels), handler),
+	)
+}
+
+// trackGateway records the session's gateway latency and connection events
+func trackGateway(s *discordgo.Session) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "bot_gateway_latency_seconds",
+		Help: "The time between the last heartbeat sent to the Discord gateway and its acknowledgement",
+	}, func() float64 {
+		return s.HeartbeatLatency().Seconds()
+	})
+	s.AddHandler(func(s *discordgo.Session, c *discordgo.Connect) {
+		gatewayEvents.WithLabelValues("connect").Inc()
+	})
+	s.AddHandler(func(s *discordgo.Session, d *discordgo.Disconnect) {
+		gatewayEvents.WithLabelValues("disconnect").Inc()
+	})
+	s.AddHandler(func(s *discordgo.Session, r *discordgo.Resumed) {
+		gatewayEvents.WithLabelValues("resume").Inc()
+	})
+}
@@ -0,0 +1,65 @@
+package prometheus
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Strum355/log"
+	"github.com/UCCNetsoc/discord-bot/database"
+	"github.com/spf13/viper"
+)
+
+type channelKey struct {
+	server  string
+	channel string
+}
+
+var (
+	// Message counts which haven't been saved yet
+	unsavedCounts     = make(map[channelKey]*database.MessageCount)
+	unsavedCountsLock sync.Mutex
+)
+
+func countMessage(server, channel string, sent, deleted int) {
+	unsavedCountsLock.Lock()
+	defer unsavedCountsLock.Unlock()
+	key := channelKey{server, channel}
+	count, ok := unsavedCounts[key]
+	if !ok {
+		count = &database.MessageCount{Server: server, Channel: channel}
+		unsavedCounts[key] = count
+	}
+	count.Sent += sent
+	count.Deleted += deleted
+}
+
+// saveMessageCounts periodically saves the message counts gathered since the last save
+func saveMessageCounts() {
+	for {
+		<-time.After(viper.GetDuration("prom.save_interval"))
+		Flush()
+	}
+}
+
+// Flush saves any message counts which haven't been saved yet, it should be called before exiting
+func Flush() {
+	unsavedCountsLock.Lock()
+	batch := unsavedCounts
+	unsavedCounts = make(map[channelKey]*database.MessageCount)
+	unsavedCountsLock.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	counts := make([]*database.MessageCount, 0, len(batch))
+	for _, count := range batch {
+		counts = append(counts, count)
+	}
+	if err := database.AddMessageCounts(counts); err != nil {
+		log.WithError(err).WithFields(log.Fields{"channels": len(counts)}).Error("Failed to save message counts")
+		// Keep the counts to retry with the next batch
+		for _, count := range counts {
+			countMessage(count.Server, count.Channel, count.Sent, count.Deleted)
+		}
+	}
+}
@@ -2,281 +2,178 @@ package prometheus
 
 import (
 	"fmt"
-	"net/http"
-	"strings"
-
-	"database/sql"
+	"time"
 
 	"github.com/Strum355/log"
 	"github.com/UCCNetsoc/discord-bot/config"
+	"github.com/UCCNetsoc/discord-bot/database"
+	"github.com/UCCNetsoc/discord-bot/server"
 	"github.com/bwmarrin/discordgo"
-
-	// Needed for mysql
-	_ "github.com/go-sql-driver/mysql"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/viper"
 )
 
+// Names of the totals kept in the stats table
+const (
+	eventsCreatedStat = "eventsCreated"
+	eventsRevokedStat = "eventsRevoked"
+)
+
+// Counters are loaded from the database on startup, so they carry on from where they were before a restart
 var (
-	memberCount = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "member_count",
-		Help: "The total number of members in the server",
-	})
-	membersJoined = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "members_joined",
+	membersJoined = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "members_joined_total",
 		Help: "The total number of members to have ever joined the server",
 	})
-	eventCount = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "event_count",
-		Help: "The total number of events ran",
+	eventsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "events_created_total",
+		Help: "The total number of events created",
 	})
-	messageCount = promauto.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "message_count",
+	eventsRevoked = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "events_revoked_total",
+		Help: "The total number of events recalled after being created",
+	})
+	messagesSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "messages_total",
 		Help: "The total number of messages sent in the server",
 	},
 		[]string{
 			"server",
 			"channel",
 		})
-	globalSession *discordgo.Session
-	globalDB      *sql.DB
+	messagesDeleted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "messages_deleted_total",
+		Help: "The total number of messages deleted in the server",
+	},
+		[]string{
+			"server",
+			"channel",
+		})
+	voiceMinutes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "voice_minutes_total",
+		Help: "The total number of minutes members have spent in each voice channel",
+	},
+		[]string{
+			"server",
+			"channel",
+		})
 )
 
-// MemberJoin is called whenever a member joins the server
-// Increments memberCount and increments membersJoined if member hasn't joined in the past
+// MemberJoin is called whenever a member registers
+// Increments membersJoined if member hasn't joined in the past
 func MemberJoin(id string) {
-	result, err := globalDB.Query("SELECT id FROM joined WHERE id = " + id)
-	defer result.Close()
+	added, err := database.RecordJoins([]string{id})
 	if err != nil {
-		log.WithError(err).Error("Failed to get joined")
+		log.WithError(err).Error("Failed to add id to joined")
 		return
 	}
-	if !result.Next() {
-		membersJoined.Inc()
-		_, err = globalDB.Exec("INSERT INTO joined VALUES(" + id + ")")
-		if err != nil {
-			log.WithError(err).Error("Failed to add id to joined")
-			return
-		}
-	}
-
-	memberCount.Inc()
-
-}
-
-// MemberLeave is called whenever a member leaves the server
-// Recalculated memberCount based on number of members with roles
-// This is due to not being able to determine whether a leaving member had a role
-func MemberLeave(id string) {
-	count := 0.
-	servers := viper.Get("discord.servers").(*config.Servers)
-	publicServer, err := globalSession.Guild(servers.PublicServer)
-	if err != nil {
-		log.WithError(err).Error("Failed to get Public Server guild")
-		return
-	}
-	for _, member := range publicServer.Members {
-		found := false
-		for _, roleID := range strings.Split(viper.GetString("discord.roles"), ",") {
-			for _, role := range member.Roles {
-				if role == roleID {
-					found = true
-					count++
-					break
-				}
-			}
-			if found {
-				break
-			}
-		}
-	}
-	memberCount.Set(count)
+	membersJoined.Add(float64(added))
 }
 
 // EventCreate is called whenever an event is created
-// It increments eventCount
 func EventCreate() {
-	eventCount.Dec()
-	_, err := globalDB.Exec("INSERT INTO stats VALUES('eventCount', 1) ON DUPLICATE KEY UPDATE value = value + 1;")
-	if err != nil {
-		log.WithError(err).Error("Failed to update eventCount")
-		return
+	eventsCreated.Inc()
+	if err := database.AddStat(eventsCreatedStat, 1); err != nil {
+		log.WithError(err).Error("Failed to update eventsCreated")
 	}
 }
 
 // EventRevoke is called whenever an event is revoked
-// Decrements eventCount
 func EventRevoke() {
-	eventCount.Dec()
-	_, err := globalDB.Exec("INSERT INTO stats VALUES('eventCount', 0) ON DUPLICATE KEY UPDATE value = value - 1;")
-	if err != nil {
-		log.WithError(err).Error("Failed to update eventCount")
-		return
+	eventsRevoked.Inc()
+	if err := database.AddStat(eventsRevokedStat, 1); err != nil {
+		log.WithError(err).Error("Failed to update eventsRevoked")
 	}
 }
 
 // MessageCreate is called whenever a message is sent
-// Increments messageCount for the given server and channel
+// The count is saved with the next batch rather than straight away
 func MessageCreate(server string, channel string) {
-	messageCount.WithLabelValues(server, channel).Inc()
-	_, err := globalDB.Exec("INSERT INTO messageCount VALUES(" + server + ", " + channel + ", 1) ON DUPLICATE KEY UPDATE value = value + 1;")
-	if err != nil {
-		log.WithError(err).Error("Failed to update messageCount")
-		return
-	}
+	messagesSent.WithLabelValues(server, channel).Inc()
+	countMessage(server, channel, 1, 0)
 }
 
 // MessageDelete is called whenever a message is deleted
-// Decrements messageCount for the given server and channel
+// The count is saved with the next batch rather than straight away
 func MessageDelete(server string, channel string) {
-	messageCount.WithLabelValues(server, channel).Dec()
-	_, err := globalDB.Exec("INSERT INTO messageCount VALUES(" + server + ", " + channel + ", 0) ON DUPLICATE KEY UPDATE value = value - 1;")
-	if err != nil {
-		log.WithError(err).Error("Failed to update messageCount")
-		return
-	}
+	messagesDeleted.WithLabelValues(server, channel).Inc()
+	countMessage(server, channel, 0, 1)
 }
 
-// InsertScheduleItem is called whenever a captain makes a new schedule entry
-// It inserts match entry into DB
-func InsertScheduleItem(game string, opponent string, time string) {
-	_, err := globalDB.Exec("INSERT INTO schedule VALUES(" + game + ", " + opponent + ", " + time + ");")
-	if err != nil {
-		log.WithError(err).Error("Failed to update schedule")
-		return
-	}
+// VoiceSession is called whenever a member leaves a voice channel, with how long they were in it
+func VoiceSession(server string, channel string, duration time.Duration) {
+	voiceMinutes.WithLabelValues(server, channel).Add(duration.Minutes())
 }
 
-func createTables() {
-	_, err := globalDB.Exec("CREATE TABLE IF NOT EXISTS stats(name VARCHAR(20) PRIMARY KEY, value INT);")
-	if err != nil {
-		log.WithError(err).Error("Failed to create table stats")
-		return
-	}
-	_, err = globalDB.Exec("CREATE TABLE IF NOT EXISTS messageCount(server VARCHAR(20), channel VARCHAR(20), value INT, PRIMARY KEY (server, channel));")
-	if err != nil {
-		log.WithError(err).Error("Failed to create table messageCount")
-		return
-	}
-	_, err = globalDB.Exec("CREATE TABLE IF NOT EXISTS joined(id VARCHAR(20));")
-	if err != nil {
-		log.WithError(err).Error("Failed to create table joined")
-		return
-	}
-	_, err = globalDB.Exec("CREATE TABLE IF NOT EXISTS schedule(game VARCHAR(32), opponent VARCHAR(8), time DATETIME")
+// loadTotals loads the stored totals into the counters
+// It must run before anything is counted, as those counts would also be in the totals
+func loadTotals() {
+	joined, err := database.JoinedCount()
 	if err != nil {
-		log.WithError(err).Error("Failed to create table schedule ")
-		return
+		log.WithError(err).Error("Failed to get joined")
 	}
-}
+	membersJoined.Add(float64(joined))
 
-func setup(s *discordgo.Session) {
-	globalSession = s
-	createTables()
-	servers := viper.Get("discord.servers").(*config.Servers)
-	publicServer, err := s.Guild(servers.PublicServer)
-	if err != nil {
-		log.WithError(err).Error("Failed to get Public Server guild")
-		return
-	}
-	newMembers := []string{}
-	for _, member := range publicServer.Members {
-		found := false
-		for _, roleID := range strings.Split(viper.GetString("discord.roles"), ",") {
-			for _, role := range member.Roles {
-				if role == roleID {
-					found = true
-					break
-				}
-			}
-			if found {
-				break
-			}
-		}
-		if found {
-			result, err := globalDB.Query("SELECT id FROM joined WHERE id = " + member.User.ID)
-			defer result.Close()
-			if err != nil {
-				log.WithError(err).Error("Failed to get joined")
-				return
-			}
-			if !result.Next() {
-				newMembers = append(newMembers, member.User.ID)
-				membersJoined.Inc()
-			}
-			memberCount.Inc()
-		}
-	}
-	if len(newMembers) > 0 {
-		_, err = globalDB.Exec("INSERT INTO joined VALUES (" + strings.Join(newMembers, "), (") + ")")
+	for stat, counter := range map[string]prometheus.Counter{eventsCreatedStat: eventsCreated, eventsRevokedStat: eventsRevoked} {
+		value, err := database.GetStat(stat)
 		if err != nil {
-			log.WithError(err).Error("Failed to add id to joined")
-			return
-		}
-	}
-
-	result, err := globalDB.Query("SELECT COUNT(*) FROM joined")
-	defer result.Close()
-	if err != nil {
-		log.WithError(err).Error("Failed to get joined")
-		return
-	}
-	if result.Next() {
-		var value float64
-		if err := result.Scan(&value); err != nil {
-			log.WithError(err).Error("Failed to read row")
-			return
+			log.WithError(err).WithFields(log.Fields{"stat": stat}).Error("Failed to get stat")
+			continue
 		}
-		membersJoined.Set(value)
+		addStored(counter, value)
 	}
 
-	result, err = globalDB.Query("SELECT value FROM stats WHERE name = 'eventCount'")
-	defer result.Close()
+	usages, err := database.VoiceTotals()
 	if err != nil {
-		log.WithError(err).Error("Failed to get stats")
-		return
+		log.WithError(err).Error("Failed to get voice totals")
 	}
-	if result.Next() {
-		var value float64
-		if err := result.Scan(&value); err != nil {
-			log.WithError(err).Error("Failed to read row")
-			return
-		}
-		eventCount.Set(value)
+	for _, usage := range usages {
+		voiceMinutes.WithLabelValues(usage.Server, usage.Channel).Add(float64(usage.Seconds) / 60)
 	}
 
-	result, err = globalDB.Query("SELECT server, channel, value FROM messageCount")
-	defer result.Close()
+	counts, err := database.MessageCounts()
 	if err != nil {
 		log.WithError(err).Error("Failed to get message count")
 		return
 	}
-	for result.Next() {
-		var server string
-		var channel string
-		var value float64
-		if err := result.Scan(&server, &channel, &value); err != nil {
-			log.WithError(err).Error("Failed to read row")
-			return
-		}
-		messageCount.WithLabelValues(server, channel).Set(value)
+	for _, count := range counts {
+		addStored(messagesSent.WithLabelValues(count.Server, count.Channel), count.Sent)
+		addStored(messagesDeleted.WithLabelValues(count.Server, count.Channel), count.Deleted)
 	}
+}
 
+// addStored adds a stored total to a counter
+// Totals used to be decremented, so old ones can be negative, which counters can't go below
+func addStored(counter prometheus.Counter, value int) {
+	if value > 0 {
+		counter.Add(float64(value))
+	}
 }
 
-// CreateExporter should be called when bot is starting
-// to set up database tables and start the prometheus exporter http server
-func CreateExporter(s *discordgo.Session) {
-	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s)/%s", viper.GetString("mysql.username"), viper.GetString("mysql.password"), viper.GetString("mysql.url"), viper.GetString("prom.dbname")))
+// recordRegistered records any registered members who haven't been seen joining
+func recordRegistered(s *discordgo.Session) {
+	added, err := database.RecordJoins(registeredMembers(s))
 	if err != nil {
-		log.WithError(err).Error("Failed to connect to db")
+		log.WithError(err).Error("Failed to add ids to joined")
 		return
 	}
-	defer db.Close()
-	globalDB = db
-	setup(s)
-	http.Handle("/metrics", promhttp.Handler())
-	http.ListenAndServe(":2112", nil)
+	membersJoined.Add(float64(added))
+}
+
+// CreateExporter should be called before the session is opened and any handlers are added
+// to load the stored stats and register the prometheus exporter, which is served once the server is started
+func CreateExporter(s *discordgo.Session) {
+	loadTotals()
+	go saveMessageCounts()
+	prometheus.MustRegister(&guildCollector{session: s})
+	trackGateway(s)
+	server.Handle(fmt.Sprintf(":%d", viper.GetInt("prom.port")), "/metrics", promhttp.Handler())
+	// Registered members are only known once the public server has been received
+	s.AddHandler(func(s *discordgo.Session, g *discordgo.GuildCreate) {
+		if g.ID == viper.Get("discord.servers").(*config.Servers).PublicServer {
+			go recordRegistered(s)
+		}
+	})
 }
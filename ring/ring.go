@@ -2,26 +2,31 @@ package ring
 
 import "github.com/bwmarrin/discordgo"
 
+// Size of the ring buffer
+const Size = 1000
+
 // Ring of messages for cache
 type Ring struct {
 	end    int
 	cycled bool
-	Buffer [1000]*discordgo.Message
+	Buffer [Size]*discordgo.Message
 }
 
 // Push messages
 func (r *Ring) Push(m []*discordgo.Message) {
 	n := len(m)
-	if n > 1000 {
-		m = m[n-r.end:]
+	if n > Size {
+		// Only the newest messages fit
+		m = m[n-Size:]
+		n = Size
 	}
-	if !r.cycled && r.end+n > 999 {
+	if !r.cycled && r.end+n > Size-1 {
 		r.cycled = true
 	}
 	// Copy
 	for _, mess := range m {
 		r.Buffer[r.end] = mess
-		r.end = (r.end + 1) % 1000
+		r.end = (r.end + 1) % Size
 	}
 }
 
@@ -34,7 +39,7 @@ func (r *Ring) Get(i int) *discordgo.Message {
 func (r *Ring) GetLast() *discordgo.Message {
 	if r.end == 0 {
 		if r.cycled {
-			return r.Buffer[999]
+			return r.Buffer[Size-1]
 		}
 		return nil
 	}
@@ -52,7 +57,34 @@ func (r *Ring) GetFirst() *discordgo.Message {
 // Len of buf
 func (r *Ring) Len() int {
 	if r.cycled {
-		return 1000
+		return Size
 	}
 	return r.end
 }
+
+// Find the message with the given id, searching from the newest message
+func (r *Ring) Find(id string) *discordgo.Message {
+	if i := r.index(id); i >= 0 {
+		return r.Buffer[i]
+	}
+	return nil
+}
+
+// Replace the message with the same id, returning false if it isn't in the buffer
+func (r *Ring) Replace(m *discordgo.Message) bool {
+	if i := r.index(m.ID); i >= 0 {
+		r.Buffer[i] = m
+		return true
+	}
+	return false
+}
+
+func (r *Ring) index(id string) int {
+	for n := 1; n <= r.Len(); n++ {
+		i := (r.end - n + Size) % Size
+		if r.Buffer[i] != nil && r.Buffer[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
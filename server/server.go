@@ -0,0 +1,158 @@
+// Package server runs the bot's HTTP listeners, such as the REST API and the prometheus exporter,
+// and shuts them down together when the bot exits
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Strum355/log"
+)
+
+// Check reports whether something the bot depends on is working
+type Check func(ctx context.Context) error
+
+var (
+	lock      sync.Mutex
+	listeners = make(map[string]*http.ServeMux)
+	servers   []*http.Server
+	checks    = make(map[string]Check)
+	stopping  bool
+)
+
+// Handle registers the handler for the pattern on the listener at addr, such as ":80"
+// Handlers registered on the same address share a listener
+func Handle(addr, pattern string, handler http.Handler) {
+	lock.Lock()
+	defer lock.Unlock()
+	mux(addr).Handle(pattern, handler)
+}
+
+// HandleFunc registers the handler function for the pattern on the listener at addr
+func HandleFunc(addr, pattern string, handler http.HandlerFunc) {
+	Handle(addr, pattern, handler)
+}
+
+// AddCheck adds a check which must pass for the bot to be ready
+func AddCheck(name string, check Check) {
+	lock.Lock()
+	defer lock.Unlock()
+	checks[name] = check
+}
+
+// mux returns the handlers of the listener at addr, creating it with the health endpoints if needed
+func mux(addr string) *http.ServeMux {
+	if m, ok := listeners[addr]; ok {
+		return m
+	}
+	m := http.NewServeMux()
+	m.HandleFunc("/healthz", healthz)
+	m.HandleFunc("/readyz", readyz)
+	listeners[addr] = m
+	return m
+}
+
+// Start listening on every address with handlers registered
+// It returns once each address is being listened on, or with the error if one can't be
+func Start() error {
+	lock.Lock()
+	defer lock.Unlock()
+	for addr, handler := range listeners {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		srv := &http.Server{
+			Addr:              addr,
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		servers = append(servers, srv)
+		go func() {
+			if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+				log.WithError(err).WithFields(log.Fields{"addr": srv.Addr}).Error("HTTP server stopped")
+			}
+		}()
+		log.WithFields(log.Fields{"addr": addr}).Info("Listening for HTTP requests")
+	}
+	return nil
+}
+
+// Shutdown stops accepting requests and waits for those in progress to finish, until ctx is done
+func Shutdown(ctx context.Context) error {
+	lock.Lock()
+	stopping = true
+	toStop := servers
+	servers = nil
+	lock.Unlock()
+	var (
+		wg       sync.WaitGroup
+		errsLock sync.Mutex
+		firstErr error
+	)
+	for _, srv := range toStop {
+		wg.Add(1)
+		go func(srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				errsLock.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errsLock.Unlock()
+			}
+		}(srv)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// healthz reports the bot is running, it fails only if the bot can't respond at all
+func healthz(w http.ResponseWriter, r *http.Request) {
+	writeStatus(w, http.StatusOK, map[string]interface{}{"status": "ok"})
+}
+
+// readyz runs every check, failing if any of them do or if the bot is shutting down
+func readyz(w http.ResponseWriter, r *http.Request) {
+	lock.Lock()
+	shuttingDown := stopping
+	toRun := make(map[string]Check, len(checks))
+	for name, check := range checks {
+		toRun[name] = check
+	}
+	lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	results := make(map[string]string, len(toRun))
+	ready := true
+	for name, check := range toRun {
+		if err := check(ctx); err != nil {
+			results[name] = err.Error()
+			ready = false
+		} else {
+			results[name] = "ok"
+		}
+	}
+
+	status := "ready"
+	code := http.StatusOK
+	if shuttingDown {
+		status = "shutting down"
+		code = http.StatusServiceUnavailable
+	} else if !ready {
+		status = "not ready"
+		code = http.StatusServiceUnavailable
+	}
+	writeStatus(w, code, map[string]interface{}{"status": status, "checks": results})
+}
+
+func writeStatus(w http.ResponseWriter, code int, body map[string]interface{}) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
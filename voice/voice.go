@@ -0,0 +1,157 @@
+// Package voice tracks how long members spend in the public server's voice channels
+package voice
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Strum355/log"
+	"github.com/UCCNetsoc/discord-bot/activity"
+	"github.com/UCCNetsoc/discord-bot/config"
+	"github.com/UCCNetsoc/discord-bot/database"
+	"github.com/UCCNetsoc/discord-bot/prometheus"
+	"github.com/bwmarrin/discordgo"
+	"github.com/spf13/viper"
+)
+
+// Channel is a voice channel and the members in it
+type Channel struct {
+	ID      string
+	Name    string
+	Members []*discordgo.Member
+}
+
+var (
+	// Sessions which haven't ended, by user
+	sessions     = make(map[string]*database.VoiceSession)
+	sessionsLock sync.Mutex
+)
+
+// Update records which voice channel a member is in, ending their session in the previous one
+// An empty channel means the member has left voice
+func Update(s *discordgo.Session, v *discordgo.VoiceState) {
+	servers := viper.Get("discord.servers").(*config.Servers)
+	if v.GuildID != servers.PublicServer {
+		return
+	}
+	channel := v.ChannelID
+	// Time spent idle in the AFK channel isn't counted
+	if guild, err := s.State.Guild(v.GuildID); err == nil && channel == guild.AfkChannelID {
+		channel = ""
+	}
+
+	now := time.Now()
+	sessionsLock.Lock()
+	current, ok := sessions[v.UserID]
+	if ok && current.Channel == channel {
+		sessionsLock.Unlock()
+		return
+	}
+	delete(sessions, v.UserID)
+	if channel != "" {
+		sessions[v.UserID] = &database.VoiceSession{Server: v.GuildID, Channel: channel, UserID: v.UserID, Started: now}
+	}
+	sessionsLock.Unlock()
+
+	if ok {
+		end(current, now)
+	}
+}
+
+// Start begins sessions for the members already in voice when the bot connects,
+// ending those of members who left while it was disconnected
+func Start(s *discordgo.Session, guildID string, states []*discordgo.VoiceState) {
+	inVoice := make(map[string]bool)
+	for _, state := range states {
+		inVoice[state.UserID] = true
+		// Voice states sent with the server don't include its id
+		Update(s, &discordgo.VoiceState{GuildID: guildID, UserID: state.UserID, ChannelID: state.ChannelID})
+	}
+	sessionsLock.Lock()
+	left := []string{}
+	for userID := range sessions {
+		if !inVoice[userID] {
+			left = append(left, userID)
+		}
+	}
+	sessionsLock.Unlock()
+	for _, userID := range left {
+		Update(s, &discordgo.VoiceState{GuildID: guildID, UserID: userID})
+	}
+}
+
+// EndAll ends every session which is still going. It should be called before exiting
+func EndAll() {
+	now := time.Now()
+	sessionsLock.Lock()
+	ended := sessions
+	sessions = make(map[string]*database.VoiceSession)
+	sessionsLock.Unlock()
+	for _, session := range ended {
+		end(session, now)
+	}
+}
+
+func end(session *database.VoiceSession, now time.Time) {
+	session.Ended = now
+	duration := session.Ended.Sub(session.Started)
+	if duration < time.Second {
+		return
+	}
+	prometheus.VoiceSession(session.Server, session.Channel, duration)
+	activity.Voice(session.Server, session.Channel, session.UserID, duration)
+	if !activity.Tracked(session.UserID) {
+		session.UserID = ""
+	}
+	if err := database.RecordVoiceSession(session); err != nil {
+		log.WithError(err).WithFields(log.Fields{"channel": session.Channel}).Error("Failed to record voice session")
+	}
+}
+
+// Channels returns the public server's voice channels with members in them, busiest first
+func Channels(s *discordgo.Session) ([]*Channel, error) {
+	servers := viper.Get("discord.servers").(*config.Servers)
+	guild, err := s.State.Guild(servers.PublicServer)
+	if err != nil {
+		return nil, err
+	}
+	s.State.RLock()
+	states := make([]*discordgo.VoiceState, len(guild.VoiceStates))
+	copy(states, guild.VoiceStates)
+	s.State.RUnlock()
+
+	byID := make(map[string]*Channel)
+	channels := []*Channel{}
+	for _, state := range states {
+		channel, ok := byID[state.ChannelID]
+		if !ok {
+			channel = &Channel{ID: state.ChannelID}
+			if c, err := s.State.Channel(state.ChannelID); err == nil {
+				channel.Name = c.Name
+			}
+			byID[state.ChannelID] = channel
+			channels = append(channels, channel)
+		}
+		member, err := s.State.Member(guild.ID, state.UserID)
+		if err != nil {
+			member = &discordgo.Member{User: &discordgo.User{ID: state.UserID}}
+		}
+		channel.Members = append(channel.Members, member)
+	}
+	sort.SliceStable(channels, func(i, j int) bool {
+		return len(channels[i].Members) > len(channels[j].Members)
+	})
+	return channels, nil
+}
+
+// Name returns the name a member is shown with in the server
+func Name(member *discordgo.Member) string {
+	if member.Nick != "" {
+		return member.Nick
+	}
+	if member.User.Username != "" {
+		return member.User.Username
+	}
+	return member.User.ID
+}